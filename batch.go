@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// batchOp is a statement to be executed in a batch, with the record to bind to it.
+type batchOp struct {
+	stmt   string
+	record []*string
+}
+
+// cmdMark toggles the mark of a row, to be edited later with cmdBatch.
+func (a *app) cmdMark(row int) {
+	row = a.result.Record(a.rowIndex(row))
+	if row >= len(a.result.Values) {
+		return
+	}
+	if a.marked[row] {
+		delete(a.marked, row)
+	} else {
+		if a.marked == nil {
+			a.marked = make(map[int]bool)
+		}
+		a.marked[row] = true
+	}
+	a.fillTable()
+}
+
+// batchRows returns the rows to be edited in a batch:
+// the marked ones or, if there are none, every record in the page.
+func (a *app) batchRows() []int {
+	var rows []int
+	for i := range a.result.Values {
+		if a.result.IsContinuation(i) {
+			continue
+		}
+		if len(a.marked) == 0 || a.marked[i] {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// batchDiff compares the original records with the edited ones,
+// using the first column as key, and returns the statements
+// needed to go from ones to the others.
+// Original records missing in edited are only deleted if withDeletes is true.
+// Records with a NULL or empty key are always inserted.
+func batchDiff(page configPage, orig, edited [][]*string, withDeletes bool) ([]batchOp, error) {
+	var deletes, updates, inserts []batchOp
+
+	origKeys := make(map[string][]*string)
+	for _, record := range orig {
+		origKeys[fieldText(record[0])] = record
+	}
+	seen := make(map[string]bool)
+	for _, record := range edited {
+		key := fieldText(record[0])
+		if key != "" && seen[key] {
+			return nil, fmt.Errorf("duplicated key %q", key)
+		}
+		seen[key] = true
+		o, ok := origKeys[key]
+		if key == "" || !ok {
+			inserts = append(inserts, batchOp{page.Insert, record})
+			continue
+		}
+		for i := range record {
+			if !sameField(record[i], o[i]) {
+				updates = append(updates, batchOp{page.Update, record})
+				break
+			}
+		}
+	}
+	for _, record := range orig {
+		if withDeletes && !seen[fieldText(record[0])] {
+			deletes = append(deletes, batchOp{page.Delete, record})
+		}
+	}
+
+	if len(inserts) > 0 && page.Insert == "" {
+		return nil, fmt.Errorf("%d new entries, but no insert statement", len(inserts))
+	}
+	if len(updates) > 0 && page.Update == "" {
+		return nil, fmt.Errorf("%d modified entries, but no update statement", len(updates))
+	}
+	if len(deletes) > 0 && page.Delete == "" {
+		return nil, fmt.Errorf("%d removed entries, but no delete statement", len(deletes))
+	}
+	ops := append(deletes, updates...)
+	return append(ops, inserts...), nil
+}
+
+// validateOps checks the values of the records to be inserted or updated.
+func validateOps(result SQLResult, ops []batchOp) error {
+	for _, op := range ops {
+		if err := result.validateRecord(op.stmt, fieldValues(op.record)); err != nil {
+			return fmt.Errorf("[%s] %w", fieldText(op.record[0]), err)
+		}
+	}
+	return nil
+}
+
+// batchExec executes all the statements in a single transaction.
+// If any of them fails, everything is rolled back.
+func batchExec(db *sqlx.DB, columns []string, ops []batchOp) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		query, args, err := sqlBind(db, op.stmt, columns, fieldValues(op.record))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%v <%q,%q>", err, query, args)
+		}
+	}
+	return tx.Commit()
+}
+
+// cmdBatch edits the marked rows (or the whole page) in a single file,
+// and applies all the changes in a transaction.
+func (a *app) cmdBatch() {
+	page := a.Pages[a.pageName]
+	if page.Insert == "" && page.Update == "" && page.Delete == "" {
+		a.showError(fmt.Errorf("page %q has no insert, update or delete statements", a.pageName))
+		return
+	}
+	if len(a.marked) == 0 {
+		if err := a.fetch(0); err != nil {
+			a.showError(err)
+			return
+		}
+	}
+	rows := a.batchRows()
+	orig := make([][]*string, len(rows))
+	for i, row := range rows {
+		orig[i] = recordFields(a.result.Values[row])
+	}
+
+	format := a.editorFormat()
+	file, err := os.CreateTemp("", "sqlview.*."+format)
+	if err != nil {
+		a.showError(err)
+		return
+	}
+	defer os.Remove(file.Name())
+	err = writeRecords(file, format, a.result.Columns, orig)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		a.showError(err)
+		return
+	}
+
+	done, closed := false, false
+	a.table.Suspend(func() {
+		for {
+			err := a.callEditor(file.Name())
+			if err == nil {
+				err = a.batchApply(file.Name(), format, orig)
+				closed = closed || (!a.result.More() && !a.result.Complete)
+			}
+			if err == nil {
+				done = true
+				return
+			}
+			fmt.Printf("Error: %s\n", err.Error())
+			if !askError() {
+				return
+			}
+		}
+	})
+	if !done && !closed {
+		return
+	}
+	a.refresh()
+}
+
+// batchApply reads an edited file and executes the changes made to it.
+func (a *app) batchApply(name string, format string, orig [][]*string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	edited, err := readRecords(f, format, a.result.Columns)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	ops, err := batchDiff(a.Pages[a.pageName], orig, edited, true)
+	if err != nil {
+		return err
+	}
+	if err = validateOps(a.result, ops); err != nil {
+		return err
+	}
+	a.closeResult()
+	return batchExec(a.db, a.result.Columns, ops)
+}
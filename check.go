@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/yaml.v3"
+)
+
+// configProblem is a problem found in a config file.
+type configProblem struct {
+	file   string
+	line   int
+	column int
+	msg    string
+}
+
+func (p configProblem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", p.file, p.line, p.column, p.msg)
+}
+
+// configChecker looks for problems in the config files.
+type configChecker struct {
+	files    []string // main config file first, then the included ones
+	docs     map[string]*yaml.Node
+	problems []configProblem
+}
+
+// yamlFind returns the node of a value in a YAML document, following a path of keys.
+// If some key is not found, it returns the last node found and false.
+func yamlFind(doc *yaml.Node, path ...string) (*yaml.Node, bool) {
+	n := doc
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, key := range path {
+		var next *yaml.Node
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == key {
+					next = n.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n.Content) {
+				next = n.Content[i]
+			}
+		}
+		if next == nil {
+			return n, false
+		}
+		n = next
+	}
+	return n, true
+}
+
+// add records a problem, located at the value of a path of keys
+// in the first config file where it is found.
+func (c *configChecker) add(path []string, format string, args ...interface{}) {
+	p := configProblem{file: c.files[0], msg: fmt.Sprintf(format, args...)}
+	var best *yaml.Node
+	for _, file := range c.files {
+		n, ok := yamlFind(c.docs[file], path...)
+		if ok {
+			p.file, best = file, n
+			break
+		}
+		if best == nil {
+			best = n
+		}
+	}
+	if best != nil {
+		p.line, p.column = best.Line, best.Column
+	}
+	c.problems = append(c.problems, p)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]configPage:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]configColumn:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkAction checks an action of a key (a page with its arguments).
+// It returns the name of the page and the arguments given to it.
+func (c *configChecker) checkAction(config config, path []string, action string) (string, []string) {
+	fields := strings.Fields(action)
+	if len(fields) == 0 {
+		c.add(path, "empty page")
+		return "", nil
+	}
+	target, ok := config.Pages[fields[0]]
+	if !ok {
+		c.add(path, "unknown page %q", fields[0])
+		return "", nil
+	}
+	positions, _ := sqlParams(target.Select, config.backslashes(target))
+	if max := maxParam(positions); max > len(fields)-1 && max > len(target.Params) {
+		c.add(path, "page %q needs %d arguments, only %d given", fields[0], max, len(fields)-1)
+	}
+	return fields[0], fields[1:]
+}
+
+// checkConfig looks for problems which can be found without connecting to a database.
+func (c *configChecker) checkConfig(config config) {
+	if config.EditorFormat != "" && !validEditorFormat(config.EditorFormat) {
+		c.add([]string{"editor-format"}, "unknown editor-format %q", config.EditorFormat)
+	}
+	if config.DefaultPage != "" {
+		if _, ok := config.Pages[config.DefaultPage]; !ok {
+			c.add([]string{"default"}, "unknown page %q", config.DefaultPage)
+		} else {
+			c.checkAction(config, []string{"default"}, config.DefaultPage)
+		}
+	}
+	for name, conn := range config.Connections {
+		if conn.Connect == "" {
+			c.add([]string{"connections", name}, "connection %q: empty connect", name)
+		}
+	}
+	for _, name := range sortedKeys(config.Pages) {
+		page := config.Pages[name]
+		path := func(keys ...string) []string {
+			return append([]string{"pages", name}, keys...)
+		}
+		if page.Select == "" {
+			c.add(path(), "page %q: no select statement", name)
+		}
+		if _, ok := config.Connections[page.Connection]; page.Connection != "" && !ok {
+			c.add(path("connection"), "page %q: unknown connection %q", name, page.Connection)
+		}
+		if page.EditorFormat != "" && !validEditorFormat(page.EditorFormat) {
+			c.add(path("editor-format"), "page %q: unknown editor-format %q", name, page.EditorFormat)
+		}
+		if !validArrays(page.Arrays) {
+			c.add(path("arrays"), "page %q: unknown arrays %q", name, page.Arrays)
+		}
+		for _, col := range sortedKeys(page.Columns) {
+			settings := page.Columns[col]
+			if err := settings.compile(col); err != nil {
+				c.add(path("columns", col), "page %q: %v", name, err)
+			}
+		}
+		params := make(map[string]bool)
+		for i, p := range page.Params {
+			ppath := path("params", strconv.Itoa(i))
+			if p.Name == "" {
+				c.add(ppath, "page %q: parameter %d has no name", name, i+1)
+			} else if params[p.Name] {
+				c.add(ppath, "page %q: duplicated parameter %q", name, p.Name)
+			}
+			params[p.Name] = true
+			switch p.Type {
+			case "", "text", "int", "date", "bool", "enum":
+			default:
+				c.add(append(ppath, "type"), "page %q: parameter %q: unknown type %q", name, p.Name, p.Type)
+				continue
+			}
+			if p.Type == "enum" && len(p.Values) == 0 {
+				c.add(ppath, "page %q: parameter %q: enum without values", name, p.Name)
+			}
+			if p.Default != "" {
+				if _, err := p.Value(p.Default); err != nil {
+					c.add(append(ppath, "default"), "page %q: invalid default: %v", name, err)
+				}
+			}
+		}
+		_, names := sqlParams(page.Select, config.backslashes(page))
+		for _, n := range names {
+			if !params[n] {
+				c.add(path("select"), "page %q: unknown parameter :%s", name, n)
+			}
+		}
+		for _, k := range sortedKeys(page.Keys) {
+			c.checkAction(config, path("keys", k), page.Keys[k])
+		}
+		for _, k := range sortedKeys(page.SwitchKeys) {
+			for _, id := range sortedKeys(page.SwitchKeys[k]) {
+				c.checkAction(config, path("switch-keys", k, id), page.SwitchKeys[k][id])
+			}
+		}
+	}
+}
+
+// checkColumns checks that the parameters used in a string
+// can be bound to the columns of a page.
+func (c *configChecker) checkColumns(path []string, what string, str string, columns []string, backslashes bool) {
+	positions, names := sqlParams(str, backslashes)
+	if max := maxParam(positions); max > len(columns) {
+		c.add(path, "%s uses $%d, but there are only %d columns", what, max, len(columns))
+	}
+	for _, n := range names {
+		found := false
+		for _, col := range columns {
+			found = found || n == col
+		}
+		if !found {
+			c.add(path, "%s uses unknown column :%s", what, n)
+		}
+	}
+}
+
+var unknownField = regexp.MustCompile(`^line (\d+): field (\S+) not found`)
+
+// checkFields decodes a config file rejecting unknown keys,
+// to report the misspelled ones.
+func (c *configChecker) checkFields(file string, data []byte) error {
+	var config config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(&config)
+	var typeErr *yaml.TypeError
+	if err == nil || err == io.EOF {
+		return nil
+	}
+	if !errors.As(err, &typeErr) {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	for _, msg := range typeErr.Errors {
+		p := configProblem{file: file, msg: msg}
+		if m := unknownField.FindStringSubmatch(msg); m != nil {
+			p.line, _ = strconv.Atoi(m[1])
+			p.column = 1
+			if n := yamlFindKey(c.docs[file], p.line, m[2]); n != nil {
+				p.column = n.Column
+			}
+			p.msg = fmt.Sprintf("unknown key %q", m[2])
+		}
+		c.problems = append(c.problems, p)
+	}
+	return nil
+}
+
+// yamlFindKey returns the node of a key in a given line of a YAML document.
+func yamlFindKey(n *yaml.Node, line int, key string) *yaml.Node {
+	if n.Kind == yaml.ScalarNode && n.Line == line && n.Value == key {
+		return n
+	}
+	for _, child := range n.Content {
+		if found := yamlFindKey(child, line, key); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// checkDB runs the select statement of every page (without returning any rows)
+// and prepares the rest of statements, to find SQL errors.
+func (c *configChecker) checkDB(a *app) {
+	for _, name := range sortedKeys(a.Pages) {
+		page := a.Pages[name]
+		path := func(keys ...string) []string {
+			return append([]string{"pages", name}, keys...)
+		}
+		if page.Select == "" {
+			continue
+		}
+		connStr, err := a.pageConnect(name)
+		if err == nil {
+			a.db, err = a.dbConnect(connStr)
+		}
+		if err != nil {
+			c.add(path(), "page %q: %v", name, err)
+			continue
+		}
+
+		positions, _ := sqlParams(page.Select, sqlBackslashes(a.db))
+		max := maxParam(positions)
+		if len(page.Params) > max {
+			max = len(page.Params)
+		}
+		var names []string
+		for _, p := range page.Params {
+			names = append(names, p.Name)
+		}
+		query, args, err := sqlBind(a.db, "SELECT * FROM ("+sqlTrim(page.Select, sqlBackslashes(a.db))+") AS sqlview LIMIT 0", names, make([]interface{}, max))
+		if err != nil {
+			c.add(path("select"), "page %q: select: %v", name, err)
+			continue
+		}
+		// nothing is modified, but statements are run inside a transaction just in case
+		tx, err := a.db.Beginx()
+		if err != nil {
+			c.add(path(), "page %q: %v", name, err)
+			continue
+		}
+		columns, err := selectColumns(tx, query, args)
+		if err != nil {
+			c.add(path("select"), "page %q: select: %v", name, err)
+		} else {
+			c.checkStatements(a.db, tx, path, name, page, columns)
+		}
+		tx.Rollback()
+	}
+}
+
+func selectColumns(tx *sqlx.Tx, query string, args []interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// checkStatements checks the statements and keys of a page,
+// once the columns of its select statement are known.
+func (c *configChecker) checkStatements(db *sqlx.DB, tx *sqlx.Tx, path func(...string) []string, name string, page configPage, columns []string) {
+	stmts := []struct {
+		key  string
+		stmt string
+	}{
+		{"insert", page.Insert},
+		{"update", page.Update},
+		{"delete", page.Delete},
+	}
+	for _, s := range stmts {
+		if s.stmt == "" {
+			continue
+		}
+		c.checkColumns(path(s.key), fmt.Sprintf("page %q: %s", name, s.key), s.stmt, columns, sqlBackslashes(db))
+		query, _, err := sqlBind(db, s.stmt, columns, make([]interface{}, len(columns)))
+		if err != nil {
+			continue
+		}
+		// a failed statement aborts the whole transaction in PostgreSQL,
+		// so each one is checked inside its own savepoint
+		if _, err := tx.Exec("SAVEPOINT sqlview_check"); err != nil {
+			c.add(path(s.key), "page %q: %s: %v", name, s.key, err)
+			continue
+		}
+		stmt, err := tx.Preparex(query)
+		if err != nil {
+			c.add(path(s.key), "page %q: %s: %v", name, s.key, err)
+			tx.Exec("ROLLBACK TO SAVEPOINT sqlview_check")
+			continue
+		}
+		stmt.Close()
+		tx.Exec("RELEASE SAVEPOINT sqlview_check")
+	}
+	for _, k := range sortedKeys(page.Keys) {
+		c.checkColumns(path("keys", k), fmt.Sprintf("page %q: key %q", name, k), page.Keys[k], columns, sqlBackslashes(db))
+	}
+	for _, g := range page.Generated {
+		found := false
+		for _, col := range columns {
+			found = found || g == col
+		}
+		if !found {
+			c.add(path("generated"), "page %q: unknown generated column %q", name, g)
+		}
+	}
+}
+
+// check looks for problems in the config file and the ones included from it,
+// and reports all of them.
+func (a *app) check(args []string) error {
+	var withDB bool
+
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.BoolVar(&withDB, "db", false, "Connect to the databases to check the SQL statements")
+	if _, err := parseSubcommand(flags, args); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	config, err := readConfigFile(a.ConfigFile, seen)
+	if err != nil {
+		return err
+	}
+	config.expandEnv()
+
+	c := configChecker{
+		files: []string{a.ConfigFile},
+		docs:  make(map[string]*yaml.Node),
+	}
+	for file := range seen {
+		if file != a.ConfigFile {
+			c.files = append(c.files, file)
+		}
+	}
+	sort.Strings(c.files[1:])
+	for _, file := range c.files {
+		var doc yaml.Node
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err = yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", file, err)
+		}
+		c.docs[file] = &doc
+		if err = c.checkFields(file, data); err != nil {
+			return err
+		}
+	}
+
+	c.checkConfig(config)
+	if withDB && len(c.problems) == 0 {
+		if err = a.readConfig(); err != nil {
+			return err
+		}
+		defer func() {
+			for _, db := range a.dbs {
+				db.Close()
+			}
+		}()
+		c.checkDB(a)
+	}
+
+	for _, p := range c.problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if len(c.problems) > 0 {
+		return fmt.Errorf("%d problems found", len(c.problems))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SQLColumn describes a column in the result of a query.
+type SQLColumn struct {
+	Name         string
+	DatabaseType string // as returned by the driver, in upper case: "INT4", "VARCHAR"...
+	Nullable     bool
+	HasNullable  bool // Nullable is known
+	Length       int64
+	HasLength    bool // Length is known (for variable length types)
+	Precision    int64
+	Scale        int64
+	HasPrecision bool // Precision and Scale are known (for decimal types)
+	ScanType     reflect.Type
+}
+
+func newSQLColumn(ct *sql.ColumnType) SQLColumn {
+	c := SQLColumn{
+		Name:         ct.Name(),
+		DatabaseType: strings.ToUpper(ct.DatabaseTypeName()),
+		ScanType:     ct.ScanType(),
+	}
+	c.Nullable, c.HasNullable = ct.Nullable()
+	c.Length, c.HasLength = ct.Length()
+	c.Precision, c.Scale, c.HasPrecision = ct.DecimalSize()
+	return c
+}
+
+var (
+	integerTypes = []string{"INT", "INT2", "INT4", "INT8", "INTEGER", "SMALLINT", "BIGINT", "TINYINT", "MEDIUMINT", "SERIAL", "BIGSERIAL", "UNSIGNED INT", "UNSIGNED BIGINT", "UNSIGNED SMALLINT", "UNSIGNED TINYINT", "UNSIGNED MEDIUMINT", "YEAR"}
+	floatTypes   = []string{"FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "REAL", "NUMERIC", "DECIMAL"}
+	boolTypes    = []string{"BOOL", "BOOLEAN"}
+	timeTypes    = []string{"DATE", "TIME", "TIMETZ", "TIMESTAMP", "TIMESTAMPTZ", "DATETIME"}
+)
+
+func oneOf(s string, list []string) bool {
+	for _, l := range list {
+		if s == l {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInteger reports whether the column holds integer numbers.
+func (c SQLColumn) IsInteger() bool {
+	return oneOf(c.DatabaseType, integerTypes)
+}
+
+// IsNumeric reports whether the column holds numbers.
+func (c SQLColumn) IsNumeric() bool {
+	return c.IsInteger() || oneOf(c.DatabaseType, floatTypes)
+}
+
+// IsBool reports whether the column holds booleans.
+func (c SQLColumn) IsBool() bool {
+	return oneOf(c.DatabaseType, boolTypes)
+}
+
+// IsJSON reports whether the column holds JSON documents.
+func (c SQLColumn) IsJSON() bool {
+	return c.DatabaseType == "JSON" || c.DatabaseType == "JSONB"
+}
+
+// IsTime reports whether the column holds dates or times.
+func (c SQLColumn) IsTime() bool {
+	return oneOf(c.DatabaseType, timeTypes)
+}
+
+var timeLayouts = []string{
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+	"15:04:05Z07:00",
+	"15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05-07",
+}
+
+// Validate checks whether a value entered by the user can be stored in the column.
+func (c SQLColumn) Validate(value interface{}) error {
+	if value == nil && c.HasNullable && !c.Nullable {
+		return fmt.Errorf("%s: cannot be NULL", c.Name)
+	}
+	if arr, ok := value.(pgArray); ok && strings.HasPrefix(c.DatabaseType, "_") {
+		for _, e := range arr {
+			elem := c
+			elem.HasNullable, elem.HasLength = false, false
+			if _, nested := e.(pgArray); !nested {
+				elem.DatabaseType = c.DatabaseType[1:]
+			}
+			if err := elem.Validate(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	if s == "" {
+		if c.HasNullable && !c.Nullable && (c.IsNumeric() || c.IsBool() || c.IsTime()) {
+			return fmt.Errorf("%s: a value is required", c.Name)
+		}
+		return nil
+	}
+	switch {
+	case c.IsJSON():
+		if !json.Valid([]byte(s)) {
+			return fmt.Errorf("%s: invalid JSON", c.Name)
+		}
+	case c.IsInteger():
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return fmt.Errorf("%s: invalid integer %q", c.Name, s)
+		}
+	case c.IsNumeric():
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("%s: invalid number %q", c.Name, s)
+		}
+	case c.IsBool():
+		if _, err := strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", c.Name, s)
+		}
+	case c.IsTime():
+		for _, layout := range timeLayouts {
+			if _, err := time.Parse(layout, s); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: invalid date or time %q", c.Name, s)
+	case c.HasLength && c.Length > 0 && int64(utf8.RuneCountInString(s)) > c.Length:
+		return fmt.Errorf("%s: too long (%d characters, maximum is %d)", c.Name, utf8.RuneCountInString(s), c.Length)
+	}
+	return nil
+}
+
+// JSON converts a value of the column into JSON.
+func (c SQLColumn) JSON(value interface{}) []byte {
+	var v interface{}
+	switch x := value.(type) {
+	case nil, int64, float64, bool, pgHstore:
+		v = x
+	case pgArray:
+		elems := make([]json.RawMessage, len(x))
+		for i, e := range x {
+			elem := c
+			if _, nested := e.(pgArray); !nested {
+				elem.DatabaseType = strings.TrimPrefix(c.DatabaseType, "_")
+			}
+			elems[i] = elem.JSON(e)
+		}
+		v = elems
+	case time.Time:
+		v = fieldString(x)
+	case jsonValue:
+		if json.Valid(x) {
+			return x
+		}
+		v = string(x)
+	case []byte:
+		if (c.IsNumeric() || c.DatabaseType == "JSON" || c.DatabaseType == "JSONB") && json.Valid(x) {
+			return x
+		}
+		v = string(x)
+	case string:
+		if (c.IsNumeric() || c.IsBool()) && json.Valid([]byte(x)) {
+			return []byte(x)
+		}
+		v = x
+	default:
+		v = sqlString(x)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(sqlString(value))
+	}
+	return data
+}
+
+// validateRecord checks the values of a record to be used in a statement,
+// ignoring the columns not used in it.
+func (r SQLResult) validateRecord(stmt string, values []interface{}) error {
+	positions, names := sqlParams(stmt, r.backslashes)
+	for i, col := range r.Types {
+		if i >= len(values) {
+			break
+		}
+		used := false
+		for _, p := range positions {
+			used = used || p == i+1
+		}
+		for _, n := range names {
+			used = used || n == col.Name
+		}
+		if !used {
+			continue
+		}
+		if err := col.Validate(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestValidateRecord(t *testing.T) {
+	result := SQLResult{
+		Types: []SQLColumn{
+			{Name: "id", DatabaseType: "INT", Nullable: false, HasNullable: true},
+			{Name: "name", DatabaseType: "VARCHAR", Length: 5, HasLength: true},
+			{Name: "price", DatabaseType: "NUMERIC"},
+			{Name: "total", DatabaseType: "MONEY"},
+		},
+	}
+	tests := []struct {
+		stmt   string
+		values []interface{}
+		ok     bool
+	}{
+		{"INSERT INTO t (name,price) VALUES ($2,$3)", []interface{}{nil, "abc", "1.5", nil}, true},
+		{"UPDATE t SET name=$2 WHERE id=$1", []interface{}{nil, "abc", "1.5", nil}, false},
+		{"UPDATE t SET name=$2 WHERE id=$1", []interface{}{"1", "abc", "x", nil}, true},
+		{"UPDATE t SET price=$3 WHERE id=$1", []interface{}{"1", "abc", "x", nil}, false},
+		{"INSERT INTO t (name) VALUES ($2)", []interface{}{nil, "abcdef", nil, nil}, false},
+		{"INSERT INTO t (name) VALUES (:name)", []interface{}{nil, "abcdef", nil, nil}, false},
+		{"INSERT INTO t (total) VALUES ($4)", []interface{}{nil, nil, nil, "$1,234.56"}, true},
+		{"DELETE FROM t WHERE name=$2", []interface{}{"x", "a", "y", nil}, true},
+	}
+	for _, test := range tests {
+		err := result.validateRecord(test.stmt, test.values)
+		if (err == nil) != test.ok {
+			t.Errorf("validateRecord(%q, %q) = %v, want ok=%v", test.stmt, test.values, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.yaml":   "include: [a.yaml, b.yaml]\npages:\n  main:\n    select: SELECT 1\n",
+		"a.yaml":      "include: [common.yaml]\npages:\n  a:\n    select: SELECT 2\n",
+		"b.yaml":      "include: [common.yaml]\npages:\n  b:\n    select: SELECT 3\n",
+		"common.yaml": "pages:\n  common:\n    select: SELECT 4\n",
+		"cycle.yaml":  "include: [loop.yaml]\n",
+		"loop.yaml":   "include: [cycle.yaml]\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	config, err := readConfigFile(filepath.Join(dir, "main.yaml"), seen)
+	if err != nil {
+		t.Fatalf("shared include: %v", err)
+	}
+	for _, page := range []string{"main", "a", "b", "common"} {
+		if _, ok := config.Pages[page]; !ok {
+			t.Errorf("shared include: page %q not read", page)
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("shared include: %d files read, want 4", len(seen))
+	}
+
+	_, err = readConfigFile(filepath.Join(dir, "cycle.yaml"), make(map[string]bool))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("include cycle: got error %v", err)
+	}
+}
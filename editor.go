@@ -1,21 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Editor is the representation of a YAML file
+// Editor is the representation of a YAML, INI or Org file
 // to be edited by the user, using a text editor,
 // in order to create or modify info from SQL.
 type Editor struct {
 	file    *os.File
 	name    string
+	format  string
 	columns []string
-	Results []interface{} // each value will always be a string or a []string
+	json    []bool        // columns holding JSON documents
+	Results []interface{} // each value will always be a string, a pgArray or nil (NULL)
 }
 
 // Close frees the resources referenced by an Editor
@@ -24,42 +30,78 @@ func (e *Editor) Close() error {
 	return os.Remove(e.name)
 }
 
-// NewEditor creates an empty YAML file,
+// NewEditor creates an empty file in the given format,
+// with every value set to NULL,
 // and prepares a Editor to be run.
-func NewEditor(columns []string) (*Editor, error) {
-	values := make([]interface{}, len(columns))
-	for i := range values {
-		values[i] = ""
+func NewEditor(format string, columns []string) (*Editor, error) {
+	return NewEditorData(format, columns, make([]interface{}, len(columns)))
+}
+
+// yamlString returns a string as a YAML scalar, quoted if needed.
+func yamlString(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return s
 	}
-	return NewEditorData(columns, values)
+	return strings.TrimSuffix(string(out), "\n")
 }
 
-// NewEditorData fills a YAML file with some values,
-// each of them must be a string or []string
+// NewEditorData fills a file in the given format with some values,
+// each of them must be a string, pgArray or nil
 // and prepares a Editor to be run.
-func NewEditorData(columns []string, values []interface{}) (*Editor, error) {
+// NULL values are written as "~".
+// In YAML files, values of type jsonValue are written as nested YAML,
+// and converted back into JSON after editing.
+func NewEditorData(format string, columns []string, values []interface{}) (*Editor, error) {
 	var e Editor
 	var err error
+	if !validEditorFormat(format) {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	e.format = format
 	e.columns = columns
-	e.file, err = os.CreateTemp("", "sqlview.*.yaml")
+	e.json = make([]bool, len(columns))
+	e.file, err = os.CreateTemp("", "sqlview.*."+format)
 	if err != nil {
 		return nil, err
 	}
 	e.name = e.file.Name()
 
+	if format != formatYAML {
+		err = writeRecords(e.file, format, columns, [][]*string{recordFields(values)})
+		if err == nil {
+			err = e.file.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &e, nil
+	}
 	for i := range columns {
-		if arr, ok := values[i].([]string); ok {
+		if doc, ok := values[i].(jsonValue); ok {
+			e.json[i] = true
+			if err = writeYAMLDocument(e.file, columns[i], doc); err != nil {
+				return nil, err
+			}
+		} else if arr, ok := values[i].(pgArray); ok {
 			fmt.Fprintf(e.file, "%s:\n", columns[i])
 			for _, elem := range arr {
-				fmt.Fprintf(e.file, "- %s\n", elem)
+				fmt.Fprintf(e.file, "- %s\n", yamlElement(elem))
 			}
 			if len(arr) == 0 {
-				fmt.Fprintf(e.file, "- \n")
+				fmt.Fprintf(e.file, "  []\n")
 			}
 		} else if values[i] == nil {
-			fmt.Fprintf(e.file, "%s: \n", columns[i])
+			fmt.Fprintf(e.file, "%s: ~\n", columns[i])
 		} else {
-			fmt.Fprintf(e.file, "%s: %v\n", columns[i], values[i])
+			switch values[i].(type) {
+			case int64, float64, bool:
+				fmt.Fprintf(e.file, "%s: %s\n", columns[i], sqlString(values[i]))
+			default:
+				// strings, []byte, dates... are quoted if needed,
+				// so they are not read back as a different type
+				fmt.Fprintf(e.file, "%s: %s\n", columns[i], yamlString(fieldString(values[i])))
+			}
 		}
 	}
 	if err = e.file.Close(); err != nil {
@@ -68,6 +110,64 @@ func NewEditorData(columns []string, values []interface{}) (*Editor, error) {
 	return &e, nil
 }
 
+// yamlElement returns an element of an array as YAML,
+// with nested arrays written as flow sequences with their elements quoted.
+func yamlElement(elem interface{}) string {
+	arr, ok := elem.(pgArray)
+	if !ok {
+		if elem == nil {
+			return "~"
+		}
+		return yamlString(fieldString(elem))
+	}
+	elems := make([]string, len(arr))
+	for i, e := range arr {
+		if sub, ok := e.(pgArray); ok {
+			elems[i] = yamlElement(sub)
+		} else if e == nil {
+			elems[i] = "~"
+		} else {
+			q, _ := json.Marshal(fieldString(e))
+			elems[i] = string(q)
+		}
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// yamlArray converts a YAML sequence read from the editor into an array.
+func yamlArray(seq []interface{}) pgArray {
+	arr := make(pgArray, len(seq))
+	for i, elem := range seq {
+		switch e := elem.(type) {
+		case nil:
+		case []interface{}:
+			arr[i] = yamlArray(e)
+		default:
+			arr[i] = fieldString(e)
+		}
+	}
+	return arr
+}
+
+// writeYAMLDocument writes the value of a JSON column as nested YAML.
+func writeYAMLDocument(w io.Writer, column string, doc jsonValue) error {
+	if doc == nil {
+		_, err := fmt.Fprintf(w, "%s: ~\n", column)
+		return err
+	}
+	y, err := jsonToYAML(doc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", column, err)
+	}
+	trimmed := bytes.TrimSpace(doc)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || y == "{}" || y == "[]" {
+		_, err = fmt.Fprintf(w, "%s: %s\n", column, y)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s:\n  %s\n", column, strings.ReplaceAll(y, "\n", "\n  "))
+	return err
+}
+
 // Edit runs a text editor with the info in a Editor,
 // and returns its result.
 func (e *Editor) Edit(execs ...string) error {
@@ -97,13 +197,25 @@ func (e *Editor) Edit(execs ...string) error {
 		return err
 	}
 
-	out := make(map[string]interface{})
+	if e.format != formatYAML {
+		records, err := readRecords(bytes.NewReader(data), e.format, e.columns)
+		if err != nil {
+			return err
+		}
+		if len(records) != 1 {
+			return fmt.Errorf("expected 1 entry, found %d", len(records))
+		}
+		e.Results = fieldValues(records[0])
+		return nil
+	}
+
+	out := make(map[string]yaml.Node)
 
 	if err = yaml.Unmarshal(data, &out); err != nil {
 		return err
 	}
 	e.Results = make([]interface{}, len(e.columns))
-	for key, value := range out {
+	for key, node := range out {
 		idx := -1
 		for i, k := range e.columns {
 			if k == key {
@@ -114,16 +226,24 @@ func (e *Editor) Edit(execs ...string) error {
 		if idx == -1 {
 			return fmt.Errorf("unexpected key %s", key)
 		}
-		if arr, ok := value.([]interface{}); ok {
-			strs := make([]string, len(arr))
-			for i := range arr {
-				strs[i] = fmt.Sprint(arr[i])
+		if (e.json[idx] && node.Tag != "!!null") || node.Kind == yaml.MappingNode {
+			doc, err := yamlToJSON(&node)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
 			}
-			e.Results[idx] = strs
+			e.Results[idx] = doc
+			continue
+		}
+		var value interface{}
+		if err = node.Decode(&value); err != nil {
+			return err
+		}
+		if arr, ok := value.([]interface{}); ok {
+			e.Results[idx] = yamlArray(arr)
 		} else if value == nil {
-			e.Results[idx] = ""
+			e.Results[idx] = nil
 		} else {
-			e.Results[idx] = fmt.Sprint(value)
+			e.Results[idx] = fieldString(value)
 		}
 	}
 	return nil
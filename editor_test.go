@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditorRoundTrip(t *testing.T) {
+	columns := []string{"empty", "zeros", "decimals", "yes", "on", "null", "int", "text", "array"}
+	values := []interface{}{
+		[]byte(""),
+		[]byte("007"),
+		[]byte("1.10"),
+		[]byte("yes"),
+		"on",
+		nil,
+		int64(42),
+		"a: b # c",
+		pgArray{"x", nil, "", "~", "NULL", pgArray{"1", nil}},
+	}
+	want := []interface{}{"", "007", "1.10", "yes", "on", nil, "42", "a: b # c", pgArray{"x", nil, "", "~", "NULL", pgArray{"1", nil}}}
+
+	editor, err := NewEditorData(formatYAML, columns, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	if err = editor.Edit("true"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range columns {
+		if !reflect.DeepEqual(editor.Results[i], want[i]) {
+			t.Errorf("%s: got %#v, want %#v", columns[i], editor.Results[i], want[i])
+		}
+	}
+}
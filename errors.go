@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// showError prints an error with the table suspended,
+// and waits for the user to press a key.
+func (a *app) showError(err error) {
+	a.table.Suspend(func() {
+		fmt.Printf("Error: %s\n", err.Error())
+		fmt.Printf("Press any key to continue...")
+		readKey()
+		fmt.Println()
+	})
+}
+
+// recoverError shows an error produced while running the query of a page,
+// keeping the current page in the table.
+// The user can retry the operation, edit the select query of the page
+// (for the rest of the session) and then retry, or go back to the table.
+func (a *app) recoverError(err error, page string, retry func() error) {
+	var pageName string
+	if fields := strings.Fields(page); len(fields) > 0 {
+		pageName = fields[0]
+	}
+	for err != nil {
+		var c rune
+		a.table.Suspend(func() {
+			fmt.Printf("Error: %s\n", err.Error())
+			actions := []askStruct{{'r', "retry"}}
+			if _, ok := a.Pages[pageName]; ok {
+				actions = append(actions, askStruct{'e', fmt.Sprintf("edit the query of page %q and retry", pageName)})
+			}
+			actions = append(actions, askStruct{'c', "continue"})
+			c = ask("What now?", actions)
+		})
+		switch c {
+		case 'r':
+			err = retry()
+		case 'e':
+			if err = a.editSelect(pageName); err == nil {
+				err = retry()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// refresh runs again the query of the current page and shows the result.
+// Queries entered by the user are only run again if the user confirms it,
+// as they can modify data (as in INSERT ... RETURNING).
+func (a *app) refresh() {
+	page := a.pageName
+	if a.query != "" {
+		page = ""
+		rerun := false
+		a.table.Suspend(func() {
+			rerun = ask("Run the query again?", []askStruct{
+				{'y', "run the query again"},
+				{'n', "keep the rows already read"},
+			}) == 'y'
+		})
+		if !rerun {
+			a.fillTable()
+			return
+		}
+	}
+	a.recoverError(a.reloadPage(), page, a.reloadPage)
+	a.fillTable()
+}
+
+// editSelect opens the select query of a page in the editor,
+// and changes it for the rest of the session.
+func (a *app) editSelect(pageName string) error {
+	file, err := os.CreateTemp("", "sqlview.*.sql")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	page := a.Pages[pageName]
+	fmt.Fprintln(file, page.Select)
+	if err = file.Close(); err != nil {
+		return err
+	}
+	a.table.Suspend(func() {
+		err = a.callEditor(file.Name())
+	})
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return err
+	}
+	page.Select = strings.TrimSpace(string(data))
+	a.Pages[pageName] = page
+	return nil
+}
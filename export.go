@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// export runs the query of a page and writes its result to stdout,
+// without starting the text interface.
+func (a *app) export(args []string) error {
+	var format string
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.StringVar(&format, "format", formatCSV, "Output format: csv, json, yaml, org or ini")
+
+	positional, err := parseSubcommand(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("export: no page specified")
+	}
+	if err = a.openPage(positional[0], positional[1:]); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err = a.result.Fetch(0); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if format == formatJSON {
+		return writeJSON(os.Stdout, a.result.Types, a.recordValues())
+	}
+	return writeRecords(os.Stdout, format, a.result.Columns, a.records())
+}
+
+// parseSubcommand parses the flags of a subcommand,
+// which can appear before or after its positional arguments,
+// and returns the positional arguments.
+func parseSubcommand(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// openPage changes to a page with some arguments.
+// Arguments are bound as "$1", "$2"... so they can contain spaces.
+func (a *app) openPage(page string, args []string) error {
+	if a.Pages[page].Select == "" {
+		return fmt.Errorf("unknown page %q", page)
+	}
+	fields := []string{page}
+	for i := range args {
+		fields = append(fields, fmt.Sprintf("$%d", i+1))
+	}
+	return a.changePage(strings.Join(fields, " "), args)
+}
+
+// records returns the records of the current page as strings,
+// with nil for NULL values,
+// skipping the rows which only hold extra elements of arrays.
+func (a *app) records() [][]*string {
+	var data [][]*string
+	for _, values := range a.recordValues() {
+		data = append(data, recordFields(values))
+	}
+	return data
+}
+
+// recordValues returns the values of the records of the current page,
+// skipping the rows which only hold extra elements of arrays.
+func (a *app) recordValues() [][]interface{} {
+	var data [][]interface{}
+	for i, values := range a.result.Values {
+		if !a.result.IsContinuation(i) {
+			data = append(data, values)
+		}
+	}
+	return data
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExportImportNull(t *testing.T) {
+	a := &app{
+		result: SQLResult{
+			Columns: []string{"id", "name", "notes"},
+			Values: [][]interface{}{
+				{int64(1), "one", nil},
+				{int64(2), "two", ""},
+				{int64(3), "~", "NULL"},
+				{int64(4), `"quoted"`, "a, \"b\""},
+				{int64(5), " spaces ", "two\nlines"},
+				{int64(6), "a | b", "\ttab: # x"},
+			},
+		},
+	}
+	page := configPage{Insert: "INSERT", Update: "UPDATE"}
+	for _, format := range []string{formatYAML, formatINI, formatOrg, formatCSV} {
+		var buf bytes.Buffer
+		if err := writeRecords(&buf, format, a.result.Columns, a.records()); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		data, err := readRecords(&buf, format, a.result.Columns)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		ops, err := batchDiff(page, a.records(), data, false)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(ops) != 0 {
+			t.Errorf("%s: unexpected changes after export and import: %v", format, ops)
+		}
+		if values := fieldValues(data[0]); values[2] != nil {
+			t.Errorf("%s: NULL imported as %#v", format, values[2])
+		}
+		if values := fieldValues(data[2]); values[1] != "~" || values[2] != "NULL" {
+			t.Errorf("%s: text imported as %#v", format, values)
+		}
+	}
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	columns := []string{"id", "name", "notes"}
+	tests := []struct {
+		csv string
+		ok  bool
+	}{
+		{"notes,id,name\nx,1,one\n", true},
+		{"id,name\n1,one\n", false},
+		{"id,name,notes,name\n1,one,x,two\n", false},
+		{"id,name,other\n1,one,x\n", false},
+	}
+	for _, test := range tests {
+		_, err := readCSV(strings.NewReader(test.csv), columns)
+		if (err == nil) != test.ok {
+			t.Errorf("readCSV(%q): error %v, want ok=%v", test.csv, err, test.ok)
+		}
+	}
+}
+
+func TestWriteRecordsNoColumns(t *testing.T) {
+	for _, format := range []string{formatYAML, formatINI, formatOrg} {
+		var buf bytes.Buffer
+		if err := writeRecords(&buf, format, nil, [][]*string{{}}); err == nil {
+			t.Errorf("%s: no error writing records without columns", format)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cespedes/tableview"
+)
+
+// configColumn holds how a column of a page is shown in the table.
+// It only changes the table: editors and exports use the values as they are.
+type configColumn struct {
+	Title     string   // name shown in the header
+	Width     int      // fixed width, truncating or padding values
+	Align     string   // left, center or right
+	Hidden    bool     // do not show the column
+	Thousands string   // thousands separator for numbers
+	Decimals  *int     // number of decimals for numbers
+	Time      string   // layout for dates and times, as in Go's time.Format
+	Timezone  string   // time zone for dates and times, as in "Europe/Madrid"
+	Bool      []string // text for true and false values
+	Truncate  int      // maximum number of characters
+	Printf    string   // format for fmt.Sprintf, applied to the value
+	Template  string   // Go template, with .Value, .Column and .Row
+
+	location *time.Location
+	template *template.Template
+}
+
+// compile checks the settings of a column, preparing them to be used.
+func (c *configColumn) compile(name string) error {
+	switch c.Align {
+	case "", "left", "center", "right":
+	default:
+		return fmt.Errorf("column %q: unknown align %q", name, c.Align)
+	}
+	if c.Bool != nil && len(c.Bool) != 2 {
+		return fmt.Errorf("column %q: bool must have 2 values: true and false", name)
+	}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", name, err)
+		}
+		c.location = loc
+	}
+	if c.Template != "" {
+		t, err := template.New(name).Parse(c.Template)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", name, err)
+		}
+		c.template = t
+	}
+	return nil
+}
+
+// compileColumns checks and prepares the column settings of all the pages.
+func compileColumns(pages map[string]configPage) error {
+	for name, page := range pages {
+		for col, c := range page.Columns {
+			if err := c.compile(col); err != nil {
+				return fmt.Errorf("page %q: %w", name, err)
+			}
+			page.Columns[col] = c
+		}
+	}
+	return nil
+}
+
+// alignment returns the tableview alignment of the column,
+// or def if it is not set.
+func (c configColumn) alignment(def int) int {
+	switch c.Align {
+	case "left":
+		return tableview.AlignLeft
+	case "center":
+		return tableview.AlignCenter
+	case "right":
+		return tableview.AlignRight
+	}
+	return def
+}
+
+// templateData is the data available to column templates.
+type templateData struct {
+	Value  interface{}            // value of the column
+	Column string                 // name of the column
+	Row    map[string]interface{} // values of all the columns in the row
+}
+
+// format returns the text to be shown for a value of column col,
+// which is in a row with the given values.
+func (c *configColumn) format(col SQLColumn, value interface{}, columns []string, values []interface{}) string {
+	decimal, isDecimal := decimalString(col, value)
+	value = naturalValue(col, value)
+	var s string
+	switch {
+	case c.template != nil:
+		data := templateData{Value: value, Column: col.Name, Row: make(map[string]interface{})}
+		for i, name := range columns {
+			data.Row[name] = values[i]
+		}
+		var b strings.Builder
+		if err := c.template.Execute(&b, data); err != nil {
+			s = "error: " + err.Error()
+		} else {
+			s = b.String()
+		}
+	case c.Printf != "":
+		s = fmt.Sprintf(c.Printf, value)
+	case isDecimal:
+		s = c.formatDecimal(decimal)
+	default:
+		s = c.formatValue(value)
+	}
+	if c.Truncate > 0 {
+		s = truncate(s, c.Truncate)
+	}
+	if c.Width > 0 {
+		s = truncate(s, c.Width)
+		s = c.pad(s)
+	}
+	return s
+}
+
+var decimalRegexp = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]*)?$`)
+
+// decimalString returns the text of a number returned as a string by the driver,
+// as NUMERIC and DECIMAL values are, so it can be formatted without converting it
+// into a float64, which would round it.
+func decimalString(col SQLColumn, value interface{}) (string, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return "", false
+	}
+	return s, col.IsNumeric() && decimalRegexp.MatchString(s)
+}
+
+// naturalValue converts the numbers and booleans returned as strings
+// by some drivers into int64, float64 or bool.
+func naturalValue(col SQLColumn, value interface{}) interface{} {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return value
+	}
+	switch {
+	case col.IsInteger():
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case col.IsNumeric():
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case col.IsBool():
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// formatValue formats a value according to its type.
+func (c *configColumn) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		if c.location != nil {
+			v = v.In(c.location)
+		}
+		if c.Time != "" {
+			return v.Format(c.Time)
+		}
+		return sqlString(v)
+	case bool:
+		if c.Bool != nil {
+			if v {
+				return c.Bool[0]
+			}
+			return c.Bool[1]
+		}
+	case int64:
+		return c.formatDecimal(strconv.FormatInt(v, 10))
+	case float64:
+		return c.formatNumber(v)
+	}
+	return sqlString(value)
+}
+
+// formatNumber formats a floating point number.
+func (c *configColumn) formatNumber(f float64) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	decimals := -1
+	if c.Decimals != nil {
+		decimals = *c.Decimals
+	}
+	s := strconv.FormatFloat(f, 'f', decimals, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	return groupThousands(intPart, c.Thousands) + frac
+}
+
+// formatDecimal formats a number written in decimal, such as "-1234.5678",
+// rounding it to the number of decimals of the column (halves away from zero)
+// without converting it into a float64.
+func (c *configColumn) formatDecimal(s string) string {
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		if s[0] == '-' {
+			sign = "-"
+		}
+		s = s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	if c.Decimals != nil {
+		n := *c.Decimals
+		if n < 0 {
+			n = 0
+		}
+		if len(frac) > n {
+			roundUp := frac[n] >= '5'
+			frac = frac[:n]
+			if roundUp {
+				digits := []byte(intPart + frac)
+				i := len(digits) - 1
+				for ; i >= 0 && digits[i] == '9'; i-- {
+					digits[i] = '0'
+				}
+				if i >= 0 {
+					digits[i]++
+				} else {
+					digits = append([]byte{'1'}, digits...)
+				}
+				intPart, frac = string(digits[:len(digits)-n]), string(digits[len(digits)-n:])
+			}
+		} else {
+			frac += strings.Repeat("0", n-len(frac))
+		}
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if strings.Trim(intPart+frac, "0") == "" {
+		sign = ""
+	}
+	s = sign + groupThousands(intPart, c.Thousands)
+	if frac != "" {
+		s += "." + frac
+	}
+	return s
+}
+
+// groupThousands adds a separator between each group of three digits
+// in an integer number.
+func groupThousands(s string, sep string) string {
+	if sep == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, d := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// truncate shortens a string to n characters, ending it with "…" if needed.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// pad adds spaces to a string to fill the width of the column.
+func (c *configColumn) pad(s string) string {
+	n := c.Width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	switch c.Align {
+	case "right":
+		return strings.Repeat(" ", n) + s
+	case "center":
+		return strings.Repeat(" ", n/2) + s + strings.Repeat(" ", n-n/2)
+	}
+	return s + strings.Repeat(" ", n)
+}
+
+// columnIndex converts a column in the table into a column in the result of the page,
+// which are different when some columns are hidden.
+// It returns -1 if col is not valid.
+func (a *app) columnIndex(col int) int {
+	if col < 0 || col >= len(a.columns) {
+		return -1
+	}
+	return a.columns[col]
+}
+
+// visibleColumns returns the columns of the result shown in the table.
+func (a *app) visibleColumns() []int {
+	var cols []int
+	settings := a.Pages[a.pageName].Columns
+	for i, name := range a.result.Columns {
+		if !settings[name].Hidden {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestFormatDecimal(t *testing.T) {
+	two, zero := 2, 0
+	tests := []struct {
+		column configColumn
+		value  interface{}
+		want   string
+	}{
+		{configColumn{Thousands: ","}, "12345678901234567890.123456789", "12,345,678,901,234,567,890.123456789"},
+		{configColumn{Decimals: &two}, []byte("1234567890123456789.125"), "1234567890123456789.13"},
+		{configColumn{Decimals: &two}, "-0.004", "0.00"},
+		{configColumn{Decimals: &two}, "-99.995", "-100.00"},
+		{configColumn{Decimals: &two, Thousands: "."}, "999999.999", "1.000.000.00"},
+		{configColumn{Decimals: &two}, "7", "7.00"},
+		{configColumn{Decimals: &zero}, "2.5", "3"},
+		{configColumn{Decimals: &two}, int64(9007199254740993), "9007199254740993.00"},
+		{configColumn{Thousands: " "}, int64(-1234567), "-1 234 567"},
+		{configColumn{Decimals: &two}, "NaN", "NaN"},
+	}
+	col := SQLColumn{Name: "n", DatabaseType: "NUMERIC"}
+	for _, test := range tests {
+		if got := test.column.format(col, test.value, nil, nil); got != test.want {
+			t.Errorf("format(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// historyEntry holds the state of a page visited before,
+// to be able to go back to it.
+type historyEntry struct {
+	pageName     string
+	pageArgs     []string
+	query        string
+	filter       string
+	sortColumn   string
+	sortDesc     bool
+	db           *sqlx.DB
+	result       SQLResult
+	row          int
+	offsetRow    int
+	offsetColumn int
+}
+
+func (a *app) saveState(row int) historyEntry {
+	h := historyEntry{
+		pageName:   a.pageName,
+		pageArgs:   a.pageArgs,
+		query:      a.query,
+		filter:     a.filter,
+		sortColumn: a.sortColumn,
+		sortDesc:   a.sortDesc,
+		db:         a.db,
+		result:     a.result,
+		row:        row,
+	}
+	// the cursor of the result is not kept in the history:
+	// records not read yet are read again by showState,
+	// unless they come from a query entered by the user
+	h.result.rows = nil
+	h.result.cancel = nil
+	if a.table != nil {
+		h.offsetRow, h.offsetColumn = a.table.GetOffset()
+	}
+	return h
+}
+
+func (a *app) restoreState(h historyEntry) {
+	a.pageName = h.pageName
+	a.pageArgs = h.pageArgs
+	a.query = h.query
+	a.filter = h.filter
+	a.sortColumn = h.sortColumn
+	a.sortDesc = h.sortDesc
+	a.db = h.db
+	a.result = h.result
+	a.marked = nil
+}
+
+// showState restores the state of a page and shows it in the table,
+// with the same selected row and scroll position.
+// Records not read when the page was left are read again,
+// except for queries entered by the user, which are never run again
+// implicitly, as they can modify data: only the rows already read are shown.
+func (a *app) showState(h historyEntry) {
+	a.restoreState(h)
+	if a.query == "" && !a.result.Complete && !a.result.More() {
+		a.recoverError(a.reloadPage(), a.pageName, a.reloadPage)
+		for len(a.result.Strings) <= h.row && a.result.More() {
+			if err := a.fetch(pageSize); err != nil {
+				a.showError(err)
+				break
+			}
+		}
+	}
+	a.fillTable()
+	a.table.Select(h.row, 0)
+	a.table.SetOffset(h.offsetRow, h.offsetColumn)
+}
+
+// goPage changes to another page, remembering the current one
+// to be able to go back.
+// If there is any error, the current page is kept.
+func (a *app) goPage(page string, args []string, row int) error {
+	h := a.saveState(row)
+	current := a.result
+	if err := a.changePage(page, args); err != nil {
+		a.restoreState(h)
+		a.result = current
+		return err
+	}
+	current.Close()
+	a.back = append(a.back, h)
+	a.forward = nil
+	a.fillTable()
+	return nil
+}
+
+// goBack returns to the previous page, if any.
+func (a *app) goBack(row int) bool {
+	if len(a.back) == 0 {
+		return false
+	}
+	a.result.Close()
+	a.forward = append(a.forward, a.saveState(row))
+	h := a.back[len(a.back)-1]
+	a.back = a.back[:len(a.back)-1]
+	a.showState(h)
+	return true
+}
+
+// goForward goes again to the page left with goBack, if any.
+func (a *app) goForward(row int) bool {
+	if len(a.forward) == 0 {
+		return false
+	}
+	a.result.Close()
+	a.back = append(a.back, a.saveState(row))
+	h := a.forward[len(a.forward)-1]
+	a.forward = a.forward[:len(a.forward)-1]
+	a.showState(h)
+	return true
+}
+
+func (h historyEntry) String() string {
+	if h.query != "" {
+		return ": " + h.query
+	}
+	return strings.Join(append([]string{h.pageName}, h.pageArgs...), " ")
+}
+
+// breadcrumb returns the chain of pages visited to get to the current one.
+func (a *app) breadcrumb() string {
+	var pages []string
+	for _, h := range a.back {
+		pages = append(pages, h.String())
+	}
+	current := historyEntry{pageName: a.pageName, pageArgs: a.pageArgs, query: a.query}
+	pages = append(pages, current.String())
+	return strings.Join(pages, " > ")
+}
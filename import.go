@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importFile reads records from a file and inserts them in a page,
+// or updates the existing ones with the same value in the first column.
+func (a *app) importFile(args []string) error {
+	var format string
+	var dryRun bool
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.StringVar(&format, "format", "", "Input format: csv, yaml, org or ini (default: from file extension)")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the statements instead of executing them")
+
+	positional, err := parseSubcommand(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 {
+		return fmt.Errorf("import: a page and a file must be specified")
+	}
+	name := positional[len(positional)-1]
+	if format == "" {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+		if format == "yml" {
+			format = formatYAML
+		}
+	}
+	if err = a.openPage(positional[0], positional[1:len(positional)-1]); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if err = a.result.Fetch(0); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := readRecords(f, format, a.result.Columns)
+	if err != nil {
+		return fmt.Errorf("import: %s: %w", name, err)
+	}
+
+	ops, err := batchDiff(a.Pages[a.pageName], a.records(), data, false)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if err = validateOps(a.result, ops); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if dryRun {
+		for _, op := range ops {
+			query, args, err := sqlBind(a.db, op.stmt, a.result.Columns, fieldValues(op.record))
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			fmt.Printf("%s %q\n", query, args)
+		}
+		return nil
+	}
+	if err = batchExec(a.db, a.result.Columns, ops); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if a.Debug {
+		log.Printf("import: %d statements executed", len(ops))
+	}
+	return nil
+}
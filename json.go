@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonPreviewLength is the maximum number of characters
+// shown in a cell holding a JSON document.
+const jsonPreviewLength = 60
+
+// jsonValue is the value of a JSON column.
+type jsonValue []byte
+
+// String returns the JSON document as it was read from the database.
+func (j jsonValue) String() string {
+	return string(j)
+}
+
+// Value converts the JSON document into a string to be sent to the database.
+func (j jsonValue) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// jsonPreview returns a JSON document in a single line,
+// shortened to jsonPreviewLength characters.
+func jsonPreview(j jsonValue) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, j); err != nil {
+		return string(j)
+	}
+	s := b.String()
+	if utf8.RuneCountInString(s) > jsonPreviewLength {
+		s = string([]rune(s)[:jsonPreviewLength-1]) + "…"
+	}
+	return s
+}
+
+// jsonToYAML converts a JSON document into YAML in block style,
+// keeping the order of the keys.
+func jsonToYAML(j jsonValue) (string, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(j, &node); err != nil {
+		return "", err
+	}
+	var clearStyle func(n *yaml.Node)
+	clearStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			clearStyle(c)
+		}
+	}
+	clearStyle(&node)
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return "", err
+	}
+	enc.Close()
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// yamlToJSON converts a YAML node into a JSON document,
+// keeping the order of the keys.
+func yamlToJSON(n *yaml.Node) (string, error) {
+	var b strings.Builder
+	var write func(n *yaml.Node) error
+	write = func(n *yaml.Node) error {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			return write(n.Content[0])
+		case yaml.AliasNode:
+			return write(n.Alias)
+		case yaml.MappingNode:
+			b.WriteByte('{')
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				key, _ := json.Marshal(n.Content[i].Value)
+				b.Write(key)
+				b.WriteByte(':')
+				if err := write(n.Content[i+1]); err != nil {
+					return err
+				}
+			}
+			b.WriteByte('}')
+		case yaml.SequenceNode:
+			b.WriteByte('[')
+			for i, c := range n.Content {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				if err := write(c); err != nil {
+					return err
+				}
+			}
+			b.WriteByte(']')
+		default:
+			var v interface{}
+			if err := n.Decode(&v); err != nil {
+				return err
+			}
+			if _, ok := v.(time.Time); ok {
+				v = n.Value
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			b.Write(data)
+		}
+		return nil
+	}
+	if err := write(n); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// jsonNode is a value in a JSON document shown in the detail view.
+type jsonNode struct {
+	key      string // key in the parent object, with quotes, if any
+	value    string // scalar values
+	open     string // "{" or "[" for objects and arrays
+	children []*jsonNode
+	folded   bool
+}
+
+// jsonTree parses a JSON document, keeping the order of the keys.
+func jsonTree(data []byte) (*jsonNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var parse func(key string) (*jsonNode, error)
+	parse = func(key string) (*jsonNode, error) {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		n := &jsonNode{key: key}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			v, _ := json.Marshal(tok)
+			n.value = string(v)
+			return n, nil
+		}
+		n.open = delim.String()
+		for dec.More() {
+			childKey := ""
+			if delim == '{' {
+				tok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				k, _ := json.Marshal(tok)
+				childKey = string(k)
+			}
+			child, err := parse(childKey)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return parse("")
+}
+
+// jsonLine is a line in the detail view.
+type jsonLine struct {
+	text string
+	node *jsonNode // node which can be folded or unfolded from this line
+}
+
+// lines returns the lines shown for a node, pretty-printed.
+func (n *jsonNode) lines(indent string, last bool) []jsonLine {
+	prefix := indent
+	if n.key != "" {
+		prefix += n.key + ": "
+	}
+	comma := ","
+	if last {
+		comma = ""
+	}
+	if n.open == "" {
+		return []jsonLine{{prefix + n.value + comma, nil}}
+	}
+	close := "}"
+	if n.open == "[" {
+		close = "]"
+	}
+	if len(n.children) == 0 {
+		return []jsonLine{{prefix + n.open + close + comma, nil}}
+	}
+	if n.folded {
+		return []jsonLine{{fmt.Sprintf("%s%s…%s (%d)%s", prefix, n.open, close, len(n.children), comma), n}}
+	}
+	lines := []jsonLine{{prefix + n.open, n}}
+	for i, c := range n.children {
+		lines = append(lines, c.lines(indent+"  ", i == len(n.children)-1)...)
+	}
+	return append(lines, jsonLine{indent + close + comma, n})
+}
+
+// fold folds or unfolds a node and all the ones inside it.
+func (n *jsonNode) fold(folded bool) {
+	n.folded = folded
+	for _, c := range n.children {
+		c.fold(folded)
+	}
+}
+
+// jsonView shows a JSON document, pretty-printed, in the terminal,
+// letting the user fold and unfold objects and arrays.
+func jsonView(title string, data []byte) error {
+	root, err := jsonTree(data)
+	if err != nil {
+		return err
+	}
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	cursor, offset := 0, 0
+	buf := make([]byte, 3)
+	for {
+		lines := root.lines("", true)
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		height-- // status line
+		if cursor >= len(lines) {
+			cursor = len(lines) - 1
+		}
+		if cursor < offset {
+			offset = cursor
+		}
+		if cursor >= offset+height {
+			offset = cursor - height + 1
+		}
+
+		fmt.Print("\x1b[H\x1b[2J")
+		for i := offset; i < len(lines) && i < offset+height; i++ {
+			text := lines[i].text
+			if utf8.RuneCountInString(text) > width {
+				text = string([]rune(text)[:width])
+			}
+			if i == cursor {
+				text = "\x1b[7m" + text + "\x1b[0m"
+			}
+			fmt.Print(text + "\r\n")
+		}
+		fmt.Printf("\x1b[%dH\x1b[7m%s  (Enter fold, +/- all, q quit)\x1b[0m", height+1, title)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		switch key := string(buf[:n]); key {
+		case "q", "\x1b", "\x03":
+			fmt.Print("\x1b[H\x1b[2J")
+			return nil
+		case "j", "\x1b[B", "\x0e":
+			if cursor < len(lines)-1 {
+				cursor++
+			}
+		case "k", "\x1b[A", "\x10":
+			if cursor > 0 {
+				cursor--
+			}
+		case " ", "\r":
+			if node := lines[cursor].node; node != nil {
+				node.folded = !node.folded
+				// keep the cursor on the line of the node
+				for i, l := range root.lines("", true) {
+					if l.node == node {
+						cursor = i
+						break
+					}
+				}
+			}
+		case "+":
+			root.fold(false)
+		case "-":
+			root.fold(true)
+			root.folded = false
+			cursor = 0
+		}
+	}
+}
+
+// cmdJSON shows the JSON document in the selected column of a row
+// or, if that column is not JSON, in the first JSON column of the row.
+func (a *app) cmdJSON(row int) {
+	row = a.result.Record(a.rowIndex(row))
+	if row >= len(a.result.Values) {
+		return
+	}
+	_, col := a.table.GetSelection()
+	col = a.columnIndex(col)
+	if col < 0 || !a.result.Types[col].IsJSON() {
+		col = -1
+		for i, t := range a.result.Types {
+			if t.IsJSON() {
+				col = i
+				break
+			}
+		}
+	}
+	if col == -1 {
+		a.showError(fmt.Errorf("page %q has no JSON columns", a.pageName))
+		return
+	}
+	doc, ok := a.result.Values[row][col].(jsonValue)
+	if !ok {
+		a.showError(fmt.Errorf("%s: NULL", a.result.Columns[col]))
+		return
+	}
+	var err error
+	a.table.Suspend(func() {
+		err = jsonView(a.result.Columns[col], doc)
+	})
+	if err != nil {
+		a.showError(err)
+	}
+}
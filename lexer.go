@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sqlToken is a piece of a SQL statement: either some text to be kept as is
+// (including string literals, quoted identifiers and comments),
+// a positional parameter ($1, $2...) or a named parameter (:name).
+type sqlToken struct {
+	text       string
+	positional bool
+	param      int    // number of a positional parameter
+	name       string // name of a named parameter
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// sqlLex splits a SQL statement in tokens.
+// Only the parameters are recognised; everything else is returned as text,
+// taking care of not looking for parameters inside:
+// - string literals ('...') and quoted identifiers ("..." and `...`)
+// - PostgreSQL dollar-quoted strings ($$...$$ and $tag$...$tag$)
+// - comments (-- ... and /* ... */)
+// Type casts (::type) are not taken as named parameters.
+// If backslashes is true, backslashes escape quotes in every string literal, as in MySQL.
+func sqlLex(query string, backslashes bool) []sqlToken {
+	var tokens []sqlToken
+	start := 0
+	text := func(end int) {
+		if end > start {
+			tokens = append(tokens, sqlToken{text: query[start:end]})
+		}
+	}
+	for i := 0; i < len(query); {
+		if j, _ := sqlSkip(query, i, backslashes); j > i {
+			i = j
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			text(i)
+			j := i + 1
+			num := 0
+			for j < len(query) && isDigit(query[j]) {
+				num = num*10 + int(query[j]-'0')
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: query[i:j], positional: true, param: num})
+			i, start = j, j
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			i += 2
+		case c == ':' && i+1 < len(query) && isIdentStart(query[i+1]):
+			text(i)
+			j := i + 1
+			for j < len(query) && isIdentChar(query[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: query[i:j], name: query[i+1 : j]})
+			i, start = j, j
+		case isIdentChar(c):
+			// identifiers and keywords are skipped as a whole
+			for i < len(query) && isIdentChar(query[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	text(len(query))
+	return tokens
+}
+
+// sqlSkip returns the end of the string literal, quoted identifier,
+// dollar-quoted string or comment starting at position i of a query,
+// and whether it is a comment.
+// It returns i if there is none of them there,
+// and the end of the query if they are not terminated.
+func sqlSkip(query string, i int, backslashes bool) (int, bool) {
+	c := query[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`':
+		// quotes are escaped by doubling them,
+		// or with a backslash in MySQL strings and PostgreSQL escape strings (E'...')
+		escapes := (backslashes && c != '`') || (c == '\'' && i > 0 && (query[i-1] == 'E' || query[i-1] == 'e'))
+		for i++; i < len(query); i++ {
+			if query[i] == '\\' && escapes {
+				i++
+			} else if query[i] == c {
+				if i+1 < len(query) && query[i+1] == c {
+					i++
+				} else {
+					break
+				}
+			}
+		}
+		if i >= len(query) {
+			return len(query), false
+		}
+		return i + 1, false
+	case c == '-' && strings.HasPrefix(query[i:], "--"):
+		if j := strings.IndexByte(query[i:], '\n'); j != -1 {
+			return i + j + 1, true
+		}
+		return len(query), true
+	case c == '/' && strings.HasPrefix(query[i:], "/*"):
+		// PostgreSQL allows nested comments
+		depth := 0
+		for i < len(query) {
+			if strings.HasPrefix(query[i:], "/*") {
+				depth++
+				i += 2
+			} else if strings.HasPrefix(query[i:], "*/") {
+				depth--
+				i += 2
+				if depth == 0 {
+					break
+				}
+			} else {
+				i++
+			}
+		}
+		return i, true
+	case c == '$' && (i == 0 || !isIdentChar(query[i-1])):
+		// dollar-quoted string: $tag$ ... $tag$
+		j := i + 1
+		for j < len(query) && isIdentChar(query[j]) {
+			j++
+		}
+		if j < len(query) && query[j] == '$' && (j == i+1 || !isDigit(query[i+1])) {
+			delim := query[i : j+1]
+			if k := strings.Index(query[j+1:], delim); k != -1 {
+				return j + 1 + k + len(delim), false
+			}
+			return len(query), false
+		}
+	}
+	return i, false
+}
+
+// sqlTrim removes the semicolons, spaces and comments at the end of a statement,
+// so it can be used as a subquery.
+func sqlTrim(query string, backslashes bool) string {
+	end := 0
+	for i := 0; i < len(query); {
+		if j, comment := sqlSkip(query, i, backslashes); j > i {
+			if !comment {
+				end = j
+			}
+			i = j
+			continue
+		}
+		if !strings.ContainsRune("; \t\r\n", rune(query[i])) {
+			end = i + 1
+		}
+		i++
+	}
+	return query[:end]
+}
+
+// sqlArg returns the index in args of the value of a parameter,
+// looking for named parameters in names.
+// It returns -1 for text, and for named parameters not found in names,
+// which are not taken as parameters.
+func sqlArg(tok sqlToken, names []string, nargs int) (int, error) {
+	if tok.positional {
+		if tok.param < 1 || tok.param > nargs {
+			return 0, fmt.Errorf("parameter %s out of range: %d arguments available", tok.text, nargs)
+		}
+		return tok.param - 1, nil
+	}
+	if tok.name != "" {
+		for i, name := range names {
+			if name == tok.name && i < nargs {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// sqlSubst replaces the parameters in a string with their values.
+func sqlSubst(str string, names []string, args []string, backslashes bool) (string, error) {
+	var res strings.Builder
+	for _, tok := range sqlLex(str, backslashes) {
+		i, err := sqlArg(tok, names, len(args))
+		if err != nil {
+			return "", err
+		}
+		if i == -1 {
+			res.WriteString(tok.text)
+		} else {
+			res.WriteString(args[i])
+		}
+	}
+	return res.String(), nil
+}
+
+// sqlParams returns the positional parameters used in a string, in ascending order,
+// and the names of its named parameters.
+func sqlParams(str string, backslashes bool) ([]int, []string) {
+	var positions []int
+	var names []string
+	seen := make(map[int]bool)
+	for _, tok := range sqlLex(str, backslashes) {
+		if tok.positional && !seen[tok.param] {
+			seen[tok.param] = true
+			positions = append(positions, tok.param)
+		}
+		if tok.name != "" {
+			names = append(names, tok.name)
+		}
+	}
+	sort.Ints(positions)
+	return positions, names
+}
+
+// maxParam returns the highest of some positional parameters, or 0 if there are none.
+func maxParam(positions []int) int {
+	if len(positions) == 0 {
+		return 0
+	}
+	return positions[len(positions)-1]
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLParams(t *testing.T) {
+	tests := []struct {
+		query       string
+		backslashes bool
+		positions   []int
+		names       []string
+	}{
+		{`SELECT * FROM t WHERE a = $2 AND b = $1 OR c = $2`, false, []int{1, 2}, nil},
+		{`SELECT * FROM t WHERE a = :a AND b = :b_2`, false, nil, []string{"a", "b_2"}},
+		{`SELECT '$1 :a', "$2", ` + "`$3`" + `, 'it''s $4'`, false, nil, nil},
+		{`SELECT $$ $1 :a $$, $tag$ $2 $$ $tag$, $3`, false, []int{3}, nil},
+		{`SELECT $$ unterminated $1`, false, nil, nil},
+		{`SELECT E'it\'s $1', $2`, false, []int{2}, nil},
+		{`SELECT 'C:\', $1`, false, []int{1}, nil},
+		{`SELECT 'it\'s $1', $2`, true, []int{2}, nil},
+		{`SELECT "a\"b $1", $2`, true, []int{2}, nil},
+		{"SELECT 1 -- $1 :a\n, $2", false, []int{2}, nil},
+		{`SELECT /* $1 /* nested $2 */ :a */ $3`, false, []int{3}, nil},
+		{`SELECT a::text, b::int[], :c::date`, false, nil, []string{"c"}},
+		{`SELECT arr[1:n], arr[:m], arr[$1:$2]`, false, []int{1, 2}, []string{"n", "m"}},
+		{`SELECT a$1, $1`, false, []int{1}, nil},
+		{`SELECT 'unterminated $1`, false, nil, nil},
+		{`SELECT 'unterminated\`, true, nil, nil},
+		{`SELECT "unterminated :a`, false, nil, nil},
+		{`SELECT /* unterminated $1`, false, nil, nil},
+	}
+	for _, test := range tests {
+		positions, names := sqlParams(test.query, test.backslashes)
+		if !reflect.DeepEqual(positions, test.positions) || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("sqlParams(%q, %v) = %v, %v, want %v, %v",
+				test.query, test.backslashes, positions, names, test.positions, test.names)
+		}
+	}
+}
+
+func TestSQLTrim(t *testing.T) {
+	tests := []struct {
+		query       string
+		backslashes bool
+		want        string
+	}{
+		{`SELECT 1`, false, `SELECT 1`},
+		{"SELECT 1; \n", false, `SELECT 1`},
+		{"SELECT 1 -- comment\n", false, `SELECT 1`},
+		{`SELECT 1 /* a /* nested */ comment */ ;`, false, `SELECT 1`},
+		{`SELECT ';'`, false, `SELECT ';'`},
+		{`SELECT $$ -- $$`, false, `SELECT $$ -- $$`},
+		{`SELECT 'it\'s';`, true, `SELECT 'it\'s'`},
+		{`SELECT 'abc`, false, `SELECT 'abc`},
+		{`SELECT 'abc\`, true, `SELECT 'abc\`},
+		{`SELECT $$abc`, false, `SELECT $$abc`},
+		{`SELECT 1 /* abc`, false, `SELECT 1`},
+	}
+	for _, test := range tests {
+		if got := sqlTrim(test.query, test.backslashes); got != test.want {
+			t.Errorf("sqlTrim(%q, %v) = %q, want %q", test.query, test.backslashes, got, test.want)
+		}
+	}
+}
+
+func TestSQLSubst(t *testing.T) {
+	names := []string{"id", "name"}
+	args := []string{"42", "bob"}
+	tests := []struct {
+		str     string
+		want    string
+		wantErr bool
+	}{
+		{`$1`, `42`, false},
+		{`user $2 :id`, `user bob 42`, false},
+		{`:id::text ':name'`, `42::text ':name'`, false},
+		{`:other`, `:other`, false},
+		{`$3`, ``, true},
+	}
+	for _, test := range tests {
+		got, err := sqlSubst(test.str, names, args, false)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("sqlSubst(%q) = %q, %v, want %q (error: %v)", test.str, got, err, test.want, test.wantErr)
+		}
+	}
+}
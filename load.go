@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	pageSize  = 200 // records read from the database each time
+	rowsAhead = 100 // rows to have loaded after the selected one
+
+	// time to wait before telling the user that something is taking long
+	slowDelay = 500 * time.Millisecond
+)
+
+// runSlow runs f and, if it takes too long, suspends the table and shows msg
+// until it finishes, letting the user press Ctrl-C to call cancel.
+func (a *app) runSlow(msg string, cancel context.CancelFunc, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	if a.table == nil {
+		return <-done
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(slowDelay):
+	}
+	var err error
+	a.table.Suspend(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		defer signal.Stop(sig)
+		fmt.Printf("%s (press Ctrl-C to cancel)\n", msg)
+		select {
+		case err = <-done:
+		case <-sig:
+			if cancel != nil {
+				cancel()
+			}
+			err = <-done
+		}
+	})
+	return err
+}
+
+// fetch reads n more records of the current page (or all of them, if n is 0).
+// Cancelling it is not an error: the records already read are kept.
+func (a *app) fetch(n int) error {
+	if !a.result.More() {
+		return nil
+	}
+	err := a.runSlow("Loading rows...", a.result.cancel, func() error {
+		return a.result.Fetch(n)
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// closeResult stops reading the records of the current page
+// before modifying the database: an open cursor keeps SQLite databases locked,
+// and can hold the only connection available.
+// The page must be read again with refresh afterwards.
+// It reports whether there was a cursor open.
+func (a *app) closeResult() bool {
+	if !a.result.More() {
+		return false
+	}
+	a.result.Close()
+	return true
+}
+
+// loadMore reads more records of the current page when the selected row
+// gets close to the last one loaded, or all of them if End is pressed.
+func (a *app) loadMore(key tcell.Key, row int) {
+	if !a.result.More() {
+		return
+	}
+	n := pageSize
+	if key == tcell.KeyEnd {
+		n = 0
+	} else if a.rowIndex(row)+rowsAhead < len(a.result.Strings) {
+		return
+	}
+	offsetRow, offsetColumn := a.table.GetOffset()
+	_, column := a.table.GetSelection()
+	if err := a.fetch(n); err != nil {
+		a.showError(err)
+	}
+	a.fillTable()
+	a.table.Select(row, column)
+	a.table.SetOffset(offsetRow, offsetColumn)
+}
+
+// loadedString describes how many records of the current page have been read.
+func (a *app) loadedString() string {
+	switch {
+	case a.result.Complete:
+		return ""
+	case a.result.More() && a.result.Estimate > 0:
+		return fmt.Sprintf(" [loaded %d of ~%d]", a.result.Records, a.result.Estimate)
+	case a.result.More():
+		return fmt.Sprintf(" [loaded %d, more available]", a.result.Records)
+	}
+	return fmt.Sprintf(" [loaded %d, cancelled]", a.result.Records)
+}
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
-	"time"
 
 	"github.com/cespedes/tableview"
 	"github.com/gdamore/tcell/v2"
 	"github.com/jmoiron/sqlx"
+	"golang.org/x/term"
 )
 
 func main() {
@@ -27,7 +29,25 @@ type app struct {
 	pageName   string
 	pageArgs   []string
 	db         *sqlx.DB
+	dbs        map[string]*sqlx.DB // open connections, by connection string
+	dsns       map[string]string   // connection strings with passwords, by connection name
 	result     SQLResult
+	marked     map[int]bool // rows marked for batch editing
+	format     string       // editor format chosen at runtime
+	query      string       // ad-hoc query shown instead of a page
+	prompt     *term.Terminal
+	filter     string // filter of the rows shown in the table
+	rows       []int  // rows of result shown in the table, if there is a filter
+	columns    []int  // columns of result shown in the table
+	search     *regexp.Regexp
+	searchMode int
+	searchText string
+	searchRow  int
+	searchUndo string
+	sortColumn string // column used to sort server-side pages
+	sortDesc   bool
+	back       []historyEntry
+	forward    []historyEntry
 	table      *tableview.TableView
 	config
 }
@@ -49,43 +69,151 @@ func (a *app) changePage(page string, args []string) error {
 	}
 	a.pageName = fields[0]
 	a.pageArgs = fields[1:]
+	a.query = ""
+	a.filter = ""
+	a.sortColumn = ""
+	a.sortDesc = false
+
+	connStr, err := a.pageConnect(a.pageName)
+	if err != nil {
+		return err
+	}
+	a.db, err = a.dbConnect(connStr)
+	if err != nil {
+		return fmt.Errorf("changePage(%s): %v", page, err)
+	}
 
 	// Bind dollars in pageArgs:
+	columns := a.result.Columns
 	for i, arg := range a.pageArgs {
-		res := ""
-		for j := strings.Index(arg, "$"); j != -1; j = strings.Index(arg, "$") {
-			res += arg[:j]
-			arg = arg[j+1:]
-			argNum := 0
-			for len(arg) > 0 && arg[0] >= '0' && arg[0] <= '9' {
-				argNum *= 10
-				argNum += int(arg[0]) - '0'
-				arg = arg[1:]
-			}
-			if len(args) > argNum-1 {
-				res += args[argNum-1]
-			} else {
-				res += fmt.Sprintf("$%d", argNum)
-			}
+		a.pageArgs[i], err = sqlSubst(arg, columns, args, false)
+		if err != nil {
+			return fmt.Errorf("changePage(%s): %v", page, err)
 		}
-		res += arg
-		a.pageArgs[i] = res
-	}
-
-	query, bindArgs := sqlBind(a.db, a.Pages[a.pageName].Select, a.pageArgs)
-	//	if a.table != nil && len(a.pageArgs) > 0 {
-	//		a.table.Suspend(func() {
-	//			fmt.Printf("QUERY=%q ARGS=%q\n", query, args)
-	//			time.Sleep(5 * time.Second)
-	//		})
-	//	}
-	a.result, err = sqlQuery(a.db, query, bindArgs...)
+	}
+
+	if err = a.askParams(); err != nil {
+		return fmt.Errorf("changePage(%s): %v", page, err)
+	}
+
+	err = a.reloadPage()
 	if err != nil {
-		err = fmt.Errorf("changePage(%s): %v <%q,%q>", page, err, query, bindArgs)
+		err = fmt.Errorf("changePage(%s): %v", page, err)
 	}
 	return err
 }
 
+// dbConnect returns a connection to a database,
+// reusing it if it was already open.
+func (a *app) dbConnect(connStr string) (*sqlx.DB, error) {
+	if db, ok := a.dbs[connStr]; ok {
+		return db, nil
+	}
+	db, err := sqlConnect(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if a.dbs == nil {
+		a.dbs = make(map[string]*sqlx.DB)
+	}
+	a.dbs[connStr] = db
+	return db, nil
+}
+
+// reloadPage runs again the select query of the current page
+// and stores its result.
+// If there is any error, the previous result is kept.
+func (a *app) reloadPage() error {
+	query := a.query
+	var bindArgs []interface{}
+	if query == "" {
+		values, err := a.paramValues()
+		if err != nil {
+			return err
+		}
+		query = a.Pages[a.pageName].Select
+		if a.serverSide() {
+			query, values, err = a.serverQuery(values)
+			if err != nil {
+				return err
+			}
+		}
+		query, bindArgs, err = sqlBind(a.db, query, a.paramNames(), values)
+		if err != nil {
+			return err
+		}
+	}
+	var result SQLResult
+	ctx, cancel := context.WithCancel(context.Background())
+	err := a.runSlow("Running query...", cancel, func() error {
+		var err error
+		result, err = sqlQuery(ctx, a.db, pageSize, a.sqlOptions(), query, bindArgs...)
+		return err
+	})
+	if err != nil {
+		cancel()
+		if a.query != "" {
+			return err
+		}
+		return fmt.Errorf("%v <%q,%q>", err, query, bindArgs)
+	}
+	a.result.Close()
+	a.result = result
+	a.marked = nil
+	return nil
+}
+
+// fillTable shows the result of the current page in the table,
+// with a leading "*" in the rows marked for batch editing,
+// NULL values shown with a marker,
+// the columns formatted as set in the page
+// and without the rows not matching the filter.
+func (a *app) fillTable() {
+	a.rows = nil
+	if a.clientFilter() {
+		match := filterFunc(a.result.Columns, a.filter)
+		a.rows = []int{}
+		for i, row := range a.result.Strings {
+			if match(row) {
+				a.rows = append(a.rows, i)
+			}
+		}
+	}
+	a.columns = a.visibleColumns()
+	settings := a.Pages[a.pageName].Columns
+	strs := a.visibleRows()
+	shown := make([][]string, len(strs))
+	for i, row := range strs {
+		rec := a.rowIndex(i)
+		shown[i] = make([]string, len(a.columns))
+		for k, j := range a.columns {
+			shown[i][k] = row[j]
+			if a.result.Values[rec][j] == nil && !a.result.IsContinuation(rec) {
+				shown[i][k] = a.nullMarker()
+			}
+		}
+		if a.marked[rec] && len(shown[i]) > 0 {
+			shown[i][0] = "* " + shown[i][0]
+		}
+	}
+	header := make([]string, len(a.columns))
+	for k, j := range a.columns {
+		header[k] = a.result.Columns[j]
+		if title := settings[header[k]].Title; title != "" {
+			header[k] = title
+		}
+	}
+	a.table.FillTable(header, shown)
+	for k, j := range a.columns {
+		align := tableview.AlignLeft
+		if t := a.result.Types[j]; t.IsNumeric() || t.DatabaseType == "MONEY" {
+			align = tableview.AlignRight
+		}
+		a.table.SetAlign(k, settings[a.result.Columns[j]].alignment(align))
+	}
+	a.table.SetTitle(a.title())
+}
+
 func keyStringMatch(str string, k tcell.Key, r rune) bool {
 	switch str {
 	case "enter":
@@ -112,6 +240,9 @@ func run(args []string) error {
 	flags.StringVar(&app.Editor, "editor", "", "Editor to use")
 	flags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage: sqlvi [options] [<page from config file>]")
+		fmt.Fprintln(os.Stderr, "       sqlvi [options] export <page> [args...] [-format <format>]")
+		fmt.Fprintln(os.Stderr, "       sqlvi [options] import <page> [args...] <file> [-format <format>] [-dry-run]")
+		fmt.Fprintln(os.Stderr, "       sqlvi [options] check [-db]")
 		fmt.Fprintln(os.Stderr, "Options:")
 		flags.PrintDefaults()
 	}
@@ -119,6 +250,25 @@ func run(args []string) error {
 	if err = flags.Parse(args[1:]); err != nil {
 		return err
 	}
+
+	if flags.Arg(0) == "check" {
+		return app.check(flags.Args()[1:])
+	}
+	if err = app.readConfig(); err != nil {
+		return err
+	}
+	defer func() {
+		for _, db := range app.dbs {
+			db.Close()
+		}
+	}()
+
+	switch flags.Arg(0) {
+	case "export":
+		return app.export(flags.Args()[1:])
+	case "import":
+		return app.importFile(flags.Args()[1:])
+	}
 	if len(flags.Args()) > 1 {
 		return fmt.Errorf("too many arguments")
 	}
@@ -126,10 +276,6 @@ func run(args []string) error {
 	if len(flags.Args()) == 1 {
 		app.pageName = flags.Args()[0]
 	}
-
-	if err = app.readConfig(); err != nil {
-		return err
-	}
 	if app.pageName == "" {
 		app.pageName = app.DefaultPage
 	}
@@ -138,41 +284,43 @@ func run(args []string) error {
 		return fmt.Errorf("no query specified")
 	}
 
-	app.db, err = sqlConnect(app.Connect)
-	if err != nil {
-		return err
-	}
 	err = app.changePage(app.pageName, nil)
 	if err != nil {
 		return err
 	}
 
 	app.table = tableview.NewTableView()
-	app.table.FillTable(app.result.Columns, app.result.Strings)
+	app.fillTable()
 	app.table.SetInputCapture(func(key tableview.Key, r rune, row int) bool {
+		if app.searchMode == searchFilter && app.serverSide() {
+			app.serverSearchKey(key, r)
+			return false
+		}
+		if app.searchMode != searchNone {
+			app.searchKey(key, r)
+			return false
+		}
+		app.loadMore(key, row)
+		rec := app.rowIndex(row)
 		for k, action := range app.Pages[app.pageName].Keys {
 			if keyStringMatch(k, key, r) {
 				app.table.Suspend(func() {
 					fmt.Printf(">>> page=%q,key=%q: switching to page %q\n", app.pageName, k, action)
 				})
-				if row < len(app.result.Strings) {
-					err = app.changePage(action, app.result.Strings[row])
-				} else {
-					err = app.changePage(action, nil)
-				}
-				if err != nil {
-					app.table.Suspend(func() {
-						fmt.Printf("Error: %s\n", err.Error())
-						os.Exit(1)
-					})
+				var pageArgs []string
+				if rec < len(app.result.Values) {
+					pageArgs = recordStrings(app.result.Values[rec])
 				}
-				app.table.FillTable(app.result.Columns, app.result.Strings)
+				app.recoverError(app.goPage(action, pageArgs, row), action, func() error {
+					return app.goPage(action, pageArgs, row)
+				})
 				return false
 			}
 		}
 		for k, sw := range app.Pages[app.pageName].SwitchKeys {
-			if keyStringMatch(k, key, r) {
-				id := app.result.Strings[row][0]
+			if keyStringMatch(k, key, r) && rec < len(app.result.Values) {
+				pageArgs := recordStrings(app.result.Values[rec])
+				id := pageArgs[0]
 				action := sw[id]
 				if action == "" {
 					break
@@ -180,14 +328,42 @@ func run(args []string) error {
 				app.table.Suspend(func() {
 					fmt.Printf(">>> page=%q,key=%q,id=%q: switching to page %q\n", app.pageName, k, id, action)
 				})
-				err = app.changePage(action, app.result.Strings[row])
-				if err != nil {
-					app.table.Suspend(func() {
-						fmt.Printf("Error: %s\n", err.Error())
-						os.Exit(1)
-					})
+				app.recoverError(app.goPage(action, pageArgs, row), action, func() error {
+					return app.goPage(action, pageArgs, row)
+				})
+				return false
+			}
+		}
+		if key == tcell.KeyRune {
+			switch r {
+			case '/':
+				app.startSearch(searchFind, row)
+				return false
+			case '&':
+				app.startSearch(searchFilter, row)
+				return false
+			case 'n':
+				if app.findNext(row+1, true) {
+					return false
+				}
+			case 'p':
+				if app.findNext(row-1, false) {
+					return false
 				}
-				app.table.FillTable(app.result.Columns, app.result.Strings)
+			case 's':
+				if app.serverSide() {
+					app.cmdSort()
+					return false
+				}
+			}
+		}
+		switch key {
+		case tcell.KeyESC, tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyCtrlP:
+			if app.goBack(row) {
+				return false
+			}
+		case tcell.KeyCtrlN:
+			if app.goForward(row) {
 				return false
 			}
 		}
@@ -224,6 +400,21 @@ func run(args []string) error {
 	app.table.NewCommand('D', "delete", func(row int) {
 		app.cmdDelete(row)
 	})
+	app.table.NewCommand('M', "mark", func(row int) {
+		app.cmdMark(row)
+	})
+	app.table.NewCommand('B', "batch edit", func(row int) {
+		app.cmdBatch()
+	})
+	app.table.NewCommand('J', "JSON", func(row int) {
+		app.cmdJSON(row)
+	})
+	app.table.NewCommand('F', "editor format", func(row int) {
+		app.cmdFormat()
+	})
+	app.table.NewCommand(':', "query", func(row int) {
+		app.cmdQuery(row)
+	})
 	app.table.Run()
 
 	return nil
@@ -232,54 +423,174 @@ func run(args []string) error {
 func (a *app) editor() {
 }
 
+// editAndExec opens the editor and then executes stmt with the edited values bound.
+// If the editing or the statement fails, the user is asked whether to open the editor again.
+// It returns true if the statement was executed successfully.
+func (a *app) editAndExec(editor *Editor, stmt string) bool {
+	done, closed := false, false
+	a.table.Suspend(func() {
+		for {
+			err := editor.Edit(a.Editor)
+			if err == nil {
+				var query string
+				var args []interface{}
+				err = a.result.validateRecord(stmt, editor.Results)
+				if err == nil {
+					query, args, err = sqlBind(a.db, stmt, a.result.Columns, editor.Results)
+				}
+				if err == nil {
+					closed = a.closeResult() || closed
+					err = sqlExec(a.db, query, args...)
+				}
+			}
+			if err == nil {
+				done = true
+				return
+			}
+			fmt.Printf("Error: %s\n", err.Error())
+			if !askError() {
+				return
+			}
+		}
+	})
+	if !done && closed {
+		a.refresh()
+	}
+	return done
+}
+
 func (a *app) cmdNew() {
-	editor, err := NewEditor(a.result.Columns)
+	insert := a.Pages[a.pageName].Insert
+	if insert == "" {
+		a.showError(fmt.Errorf("page %q has no insert statement", a.pageName))
+		return
+	}
+	values := make([]interface{}, len(a.result.Columns))
+	for i, t := range a.result.Types {
+		if t.IsJSON() {
+			values[i] = jsonValue(nil)
+		}
+	}
+	editor, err := NewEditorData(a.editorFormat(), a.result.Columns, values)
 	if err != nil {
-		panic(err)
+		a.showError(err)
+		return
 	}
 	defer editor.Close()
 
-	a.table.Suspend(func() {
-		err = editor.Edit(a.Editor)
-	})
-	if err != nil {
-		panic(err)
+	if !a.editAndExec(editor, insert) {
+		return
 	}
-	a.table.Suspend(func() {
-		fmt.Printf("cmdNew(): %+#v\n", editor.Results)
-	})
-
+	a.refresh()
 }
 
 func (a *app) cmdCopy(row int) {
-	a.table.Suspend(func() {
-		fmt.Printf("copying entry (TODO): row=%d\n", row)
-		time.Sleep(time.Second)
-	})
+	row = a.result.Record(a.rowIndex(row))
+	insert := a.Pages[a.pageName].Insert
+	if insert == "" {
+		a.showError(fmt.Errorf("page %q has no insert statement", a.pageName))
+		return
+	}
+	if row >= len(a.result.Values) {
+		return
+	}
+	values := make([]interface{}, len(a.result.Columns))
+	copy(values, a.result.Values[row])
+	for i, column := range a.result.Columns {
+		for _, g := range a.Pages[a.pageName].Generated {
+			if column == g {
+				values[i] = nil
+			}
+		}
+	}
+	editor, err := NewEditorData(a.editorFormat(), a.result.Columns, values)
+	if err != nil {
+		a.showError(err)
+		return
+	}
+	defer editor.Close()
+
+	if !a.editAndExec(editor, insert) {
+		return
+	}
+	a.refresh()
 }
 
 func (a *app) cmdEdit(row int) {
-	editor, err := NewEditorData(a.result.Columns, a.result.Values[row])
+	row = a.result.Record(a.rowIndex(row))
+	update := a.Pages[a.pageName].Update
+	if update == "" {
+		a.showError(fmt.Errorf("page %q has no update statement", a.pageName))
+		return
+	}
+	if row >= len(a.result.Values) {
+		return
+	}
+	editor, err := NewEditorData(a.editorFormat(), a.result.Columns, a.result.Values[row])
 	if err != nil {
-		panic(err)
+		a.showError(err)
+		return
 	}
 	defer editor.Close()
 
-	a.table.Suspend(func() {
-		err = editor.Edit(a.Editor)
-	})
-	if err != nil {
-		panic(err)
+	if !a.editAndExec(editor, update) {
+		return
+	}
+	a.refresh()
+}
+
+func (a *app) cmdDelete(row int) {
+	row = a.result.Record(a.rowIndex(row))
+	del := a.Pages[a.pageName].Delete
+	if del == "" {
+		a.showError(fmt.Errorf("page %q has no delete statement", a.pageName))
+		return
 	}
+	if row >= len(a.result.Values) {
+		return
+	}
+	confirmed := false
 	a.table.Suspend(func() {
-		fmt.Printf("cmdEdit(): %+#v\n", editor.Results)
+		strs := recordStrings(a.result.Values[row])
+		for i, column := range a.result.Columns {
+			fmt.Printf("%s: %s\n", column, strs[i])
+		}
+		c := ask("Delete this entry?", []askStruct{
+			{'y', "delete this entry"},
+			{'n', "keep this entry"},
+		})
+		confirmed = c == 'y'
 	})
+	if !confirmed {
+		return
+	}
 
+	query, args, err := sqlBind(a.db, del, a.result.Columns, a.result.Values[row])
+	if err == nil {
+		a.closeResult()
+		err = sqlExec(a.db, query, args...)
+	}
+	if err != nil {
+		a.showError(fmt.Errorf("cmdDelete(): %v <%q,%q>", err, query, args))
+	}
+	a.refresh()
 }
 
-func (a *app) cmdDelete(row int) {
+func (a *app) cmdFormat() {
 	a.table.Suspend(func() {
-		fmt.Printf("deleting entry (TODO): row=%d\n", row)
-		time.Sleep(time.Second)
+		fmt.Printf("Current editor format: %s\n", a.editorFormat())
+		c := ask("New editor format?", []askStruct{
+			{'y', "YAML"},
+			{'i', "INI"},
+			{'o', "Org table"},
+		})
+		switch c {
+		case 'y':
+			a.format = formatYAML
+		case 'i':
+			a.format = formatINI
+		case 'o':
+			a.format = formatOrg
+		}
 	})
 }
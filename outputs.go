@@ -2,21 +2,90 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
 )
 
-func writeOrgTable(w io.Writer, columns []string, data [][]string) {
+// nullField is the text used for NULL values in YAML, INI and Org files.
+const nullField = "~"
+
+// textField returns a field as written in INI and Org files:
+// nullField for NULL, and values which would be read back as something else
+// (such as the text "~", spaces at the ends, line breaks or "|")
+// double-quoted, with escapes as in Go.
+func textField(f *string) string {
+	if f == nil {
+		return nullField
+	}
+	s := *f
+	if s == nullField || strings.HasPrefix(s, `"`) || strings.TrimSpace(s) != s || strings.ContainsAny(s, "|\r\n") {
+		return strings.ReplaceAll(strconv.Quote(s), "|", `\x7c`)
+	}
+	return s
+}
+
+// parseTextField converts a field read from an INI or Org file back into its value.
+func parseTextField(s string) (*string, error) {
+	switch {
+	case s == nullField:
+		return nil, nil
+	case strings.HasPrefix(s, `"`):
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted value %s", s)
+		}
+		return &u, nil
+	}
+	return &s, nil
+}
+
+// yamlField returns a field as written in YAML files, in a single line:
+// nullField for NULL, and other values quoted if needed.
+func yamlField(f *string) string {
+	if f == nil {
+		return nullField
+	}
+	s := yamlString(*f)
+	if strings.Contains(s, "\n") {
+		return strconv.Quote(*f)
+	}
+	return s
+}
+
+// parseYAMLField converts a field read from a YAML file back into its value.
+// Text which is not a valid YAML scalar is taken as it is.
+func parseYAMLField(s string) *string {
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &n); err != nil || len(n.Content) != 1 || n.Content[0].Kind != yaml.ScalarNode {
+		return &s
+	}
+	if n.Content[0].Tag == "!!null" {
+		return nil
+	}
+	return &n.Content[0].Value
+}
+
+func writeOrgTable(w io.Writer, columns []string, data [][]*string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to write")
+	}
+	cells := make([][]string, len(data))
 	widths := make([]int, len(columns))
 	for i, x := range columns {
 		widths[i] = utf8.RuneCountInString(x)
 	}
-	for _, x := range data {
+	for j, x := range data {
+		cells[j] = make([]string, len(x))
 		for i, y := range x {
-			if utf8.RuneCountInString(y) > widths[i] {
-				widths[i] = utf8.RuneCountInString(y)
+			cells[j][i] = textField(y)
+			if utf8.RuneCountInString(cells[j][i]) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cells[j][i])
 			}
 		}
 	}
@@ -30,14 +99,15 @@ func writeOrgTable(w io.Writer, columns []string, data [][]string) {
 		fmt.Fprintf(w, " %-*s |", widths[i], x)
 	}
 	fmt.Fprint(w, "\n", line, "\n")
-	for _, x := range data {
+	for _, x := range cells {
 		fmt.Fprintf(w, "|")
 		for i, y := range x {
 			fmt.Fprintf(w, " %-*s |", widths[i], y)
 		}
 		fmt.Fprintf(w, "\n")
 	}
-	fmt.Fprintln(w, line)
+	_, err := fmt.Fprintln(w, line)
+	return err
 }
 
 func readOrgLine(line string) []string {
@@ -52,7 +122,7 @@ func readOrgLine(line string) []string {
 	return s
 }
 
-func readOrgTable(r io.Reader, columns []string) (data [][]string, err error) {
+func readOrgTable(r io.Reader, columns []string) (data [][]*string, err error) {
 	lineNo := 0
 	s := bufio.NewScanner(r)
 	for s.Scan() {
@@ -86,30 +156,37 @@ func readOrgTable(r io.Reader, columns []string) (data [][]string, err error) {
 		if len(s) != len(columns) {
 			return nil, fmt.Errorf("wrong number of columns in line %d", lineNo)
 		}
-		data = append(data, s)
+		record := make([]*string, len(s))
+		for i := range s {
+			if record[i], err = parseTextField(s[i]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+		data = append(data, record)
 	}
 	return data, nil
 }
 
-func writeINI(w io.Writer, columns []string, data [][]string) {
+func writeINI(w io.Writer, columns []string, data [][]*string) error {
 	if len(columns) == 0 {
-		panic("writeINI: columns = 0")
+		return fmt.Errorf("no columns to write")
 	}
 	for i, entry := range data {
 		if len(entry) != len(columns) {
-			panic("writeINI: len(entry) != len(columns)")
+			return fmt.Errorf("record %d: %d fields, expected %d", i+1, len(entry), len(columns))
 		}
 		if i > 0 {
 			fmt.Fprintln(w)
 		}
-		fmt.Fprintf(w, "[%s]\n", entry[0])
+		fmt.Fprintf(w, "[%s]\n", textField(entry[0]))
 		for j := range entry[1:] {
-			fmt.Fprintf(w, "%s = %s\n", columns[j+1], entry[j+1])
+			fmt.Fprintf(w, "%s = %s\n", columns[j+1], textField(entry[j+1]))
 		}
 	}
+	return nil
 }
 
-func readINI(r io.Reader, columns []string) (data [][]string, err error) {
+func readINI(r io.Reader, columns []string) (data [][]*string, err error) {
 	if len(columns) < 1 {
 		return nil, fmt.Errorf("no columns to read?")
 	}
@@ -121,11 +198,16 @@ func readINI(r io.Reader, columns []string) (data [][]string, err error) {
 		if line == "" {
 			continue
 		}
-		record := make([]string, len(columns))
+		record := make([]*string, len(columns))
+		for i := range record {
+			record[i] = new(string)
+		}
 		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
 			return nil, fmt.Errorf("wrong section header in line %d", lineNo)
 		}
-		record[0] = line[1 : len(line)-1]
+		if record[0], err = parseTextField(line[1 : len(line)-1]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
 		for s.Scan() {
 			lineNo++
 			line = strings.TrimSpace(s.Text())
@@ -144,7 +226,9 @@ func readINI(r io.Reader, columns []string) (data [][]string, err error) {
 			for i, c := range columns[1:] {
 				if key == c {
 					found = true
-					record[i+1] = value
+					if record[i+1], err = parseTextField(value); err != nil {
+						return nil, fmt.Errorf("line %d: %w", lineNo, err)
+					}
 					break
 				}
 			}
@@ -157,24 +241,25 @@ func readINI(r io.Reader, columns []string) (data [][]string, err error) {
 	return data, nil
 }
 
-func writeYAML(w io.Writer, columns []string, data [][]string) {
+func writeYAML(w io.Writer, columns []string, data [][]*string) error {
 	if len(columns) == 0 {
-		panic("writeYAML: columns = 0")
+		return fmt.Errorf("no columns to write")
 	}
 	for i, entry := range data {
 		if len(entry) != len(columns) {
-			panic("writeYAML: len(entry) != len(columns)")
+			return fmt.Errorf("record %d: %d fields, expected %d", i+1, len(entry), len(columns))
 		}
 		if i > 0 {
 			fmt.Fprintln(w)
 		}
 		for j := range entry {
-			fmt.Fprintf(w, "%s: %s\n", columns[j], entry[j])
+			fmt.Fprintf(w, "%s: %s\n", columns[j], yamlField(entry[j]))
 		}
 	}
+	return nil
 }
 
-func readYAML(r io.Reader, columns []string) (data [][]string, err error) {
+func readYAML(r io.Reader, columns []string) (data [][]*string, err error) {
 	if len(columns) < 1 {
 		return nil, fmt.Errorf("no columns to read?")
 	}
@@ -187,7 +272,10 @@ func readYAML(r io.Reader, columns []string) (data [][]string, err error) {
 		if line == "" {
 			continue
 		}
-		record := make([]string, len(columns))
+		record := make([]*string, len(columns))
+		for i := range record {
+			record[i] = new(string)
+		}
 		for {
 			line = strings.TrimSpace(s.Text())
 			if line == "" {
@@ -205,7 +293,7 @@ func readYAML(r io.Reader, columns []string) (data [][]string, err error) {
 			for i, c := range columns {
 				if key == c {
 					found = true
-					record[i] = value
+					record[i] = parseYAMLField(value)
 					break
 				}
 			}
@@ -222,3 +310,221 @@ func readYAML(r io.Reader, columns []string) (data [][]string, err error) {
 	}
 	return data, nil
 }
+
+// Supported formats; only YAML, INI and Org tables can be used in the editor,
+// and JSON is only written from the values of a SQLResult
+const (
+	formatYAML = "yaml"
+	formatINI  = "ini"
+	formatOrg  = "org"
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+func validEditorFormat(format string) bool {
+	return format == formatYAML || format == formatINI || format == formatOrg
+}
+
+// writeCSV writes records as CSV, with NULL values written as empty fields
+// and empty strings as "", as PostgreSQL's COPY does.
+func writeCSV(w io.Writer, columns []string, data [][]*string) error {
+	bw := bufio.NewWriter(w)
+	header := make([]*string, len(columns))
+	for i := range columns {
+		header[i] = &columns[i]
+	}
+	for _, record := range append([][]*string{header}, data...) {
+		for i, f := range record {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			if f == nil {
+				continue
+			}
+			if *f == "" || strings.ContainsAny(*f, "\",\r\n") || strings.TrimSpace(*f) != *f {
+				bw.WriteString(`"` + strings.ReplaceAll(*f, `"`, `""`) + `"`)
+			} else {
+				bw.WriteString(*f)
+			}
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// writeJSON writes records as an array of JSON objects,
+// with the types of their columns.
+func writeJSON(w io.Writer, columns []SQLColumn, data [][]interface{}) error {
+	fmt.Fprint(w, "[")
+	for i, entry := range data {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "\n  {")
+		for j := range entry {
+			if j > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			key, _ := json.Marshal(columns[j].Name)
+			fmt.Fprintf(w, "%s: %s", key, columns[j].JSON(entry[j]))
+		}
+		fmt.Fprint(w, "}")
+	}
+	_, err := fmt.Fprint(w, "\n]\n")
+	return err
+}
+
+// readCSVRecord reads a line of a CSV file (more than one if there are
+// line breaks in quoted fields), with empty fields returned as nil,
+// and empty quoted ones ("") as empty strings.
+// It returns io.EOF if there are no more lines.
+func readCSVRecord(r *bufio.Reader, lineNo *int) ([]*string, error) {
+	var record []*string
+	var field strings.Builder
+	quoted, inQuotes, empty := false, false, true
+	add := func() {
+		if quoted || field.Len() > 0 {
+			s := field.String()
+			record = append(record, &s)
+		} else {
+			record = append(record, nil)
+		}
+		field.Reset()
+		quoted = false
+	}
+	*lineNo++
+	for {
+		c, _, err := r.ReadRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, fmt.Errorf("line %d: unterminated quoted field", *lineNo)
+			}
+			if empty {
+				return nil, io.EOF
+			}
+			add()
+			return record, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		empty = false
+		switch {
+		case inQuotes && c == '"':
+			if next, _, err := r.ReadRune(); err == nil && next == '"' {
+				field.WriteRune('"')
+			} else {
+				if err == nil {
+					r.UnreadRune()
+				}
+				inQuotes = false
+			}
+		case inQuotes:
+			if c == '\n' {
+				*lineNo++
+			}
+			field.WriteRune(c)
+		case c == '"' && field.Len() == 0 && !quoted:
+			inQuotes, quoted = true, true
+		case c == ',':
+			add()
+		case c == '\r':
+		case c == '\n' && len(record) == 0 && field.Len() == 0 && !quoted:
+			// empty lines are skipped
+			*lineNo++
+			empty = true
+		case c == '\n':
+			add()
+			return record, nil
+		default:
+			field.WriteRune(c)
+		}
+	}
+}
+
+// readCSV reads records from a CSV file, whose first line must hold
+// the names of all the columns, in any order.
+// Empty fields are read as NULL, and empty quoted ones ("") as empty strings.
+func readCSV(r io.Reader, columns []string) (data [][]*string, err error) {
+	br := bufio.NewReader(r)
+	lineNo := 0
+	header, err := readCSVRecord(br, &lineNo)
+	if err != nil {
+		return nil, err
+	}
+	index := make([]int, len(header))
+	for i, h := range header {
+		index[i] = -1
+		for j, col := range columns {
+			if fieldText(h) == col {
+				index[i] = j
+				break
+			}
+		}
+		if index[i] == -1 {
+			return nil, fmt.Errorf("line 1: unknown column %q", fieldText(h))
+		}
+	}
+	// missing columns would be taken as NULL, changing the existing records
+	for j, col := range columns {
+		n := 0
+		for _, i := range index {
+			if i == j {
+				n++
+			}
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("line 1: missing column %q", col)
+		}
+		if n > 1 {
+			return nil, fmt.Errorf("line 1: duplicated column %q", col)
+		}
+	}
+	for {
+		entry, err := readCSVRecord(br, &lineNo)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) != len(header) {
+			return nil, fmt.Errorf("line %d: wrong number of fields", lineNo)
+		}
+		record := make([]*string, len(columns))
+		for i := range entry {
+			record[index[i]] = entry[i]
+		}
+		data = append(data, record)
+	}
+}
+
+// writeRecords writes some records in one of the supported formats.
+func writeRecords(w io.Writer, format string, columns []string, data [][]*string) error {
+	switch format {
+	case formatYAML:
+		return writeYAML(w, columns, data)
+	case formatINI:
+		return writeINI(w, columns, data)
+	case formatOrg:
+		return writeOrgTable(w, columns, data)
+	case formatCSV:
+		return writeCSV(w, columns, data)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// readRecords reads some records in one of the supported formats.
+func readRecords(r io.Reader, format string, columns []string) ([][]*string, error) {
+	switch format {
+	case formatYAML:
+		return readYAML(r, columns)
+	case formatINI:
+		return readINI(r, columns)
+	case formatOrg:
+		return readOrgTable(r, columns)
+	case formatCSV:
+		return readCSV(r, columns)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
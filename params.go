@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// configParam is a parameter of a page, bound to $1, $2... in the order they are declared.
+type configParam struct {
+	Name    string
+	Type    string // int, text, date, bool or enum
+	Default string
+	Prompt  string
+	Values  []string // valid values for enum
+}
+
+// Value converts a page argument into the type of the parameter.
+func (p configParam) Value(s string) (interface{}, error) {
+	switch p.Type {
+	case "", "text":
+		return s, nil
+	case "int":
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid integer %q", p.Name, s)
+		}
+		return i, nil
+	case "date":
+		t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid date %q (must be YYYY-MM-DD)", p.Name, s)
+		}
+		return t, nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid boolean %q", p.Name, s)
+		}
+		return b, nil
+	case "enum":
+		for _, v := range p.Values {
+			if s == v {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q is not one of %s", p.Name, s, strings.Join(p.Values, ", "))
+	}
+	return nil, fmt.Errorf("%s: unknown type %q", p.Name, p.Type)
+}
+
+// paramValues returns the arguments of the current page,
+// converted to the types of its parameters.
+func (a *app) paramValues() ([]interface{}, error) {
+	params := a.Pages[a.pageName].Params
+	values := sliceStringToAny(a.pageArgs)
+	for i := range values {
+		if i >= len(params) {
+			break
+		}
+		v, err := params[i].Value(a.pageArgs[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// paramNames returns the names of the parameters of the current page.
+func (a *app) paramNames() []string {
+	var names []string
+	for _, p := range a.Pages[a.pageName].Params {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// askParams asks the user for the parameters of the current page
+// not given as arguments.
+func (a *app) askParams() error {
+	params := a.Pages[a.pageName].Params
+	if len(a.pageArgs) >= len(params) {
+		return nil
+	}
+	var err error
+	ask := func() {
+		err = a.readParams(params)
+	}
+	if a.table != nil {
+		a.table.Suspend(ask)
+	} else {
+		ask()
+	}
+	return err
+}
+
+// readParams reads the missing parameters from the terminal,
+// asking again for the invalid ones.
+// Prompts are written to stderr, to keep stdout clean for export.
+func (a *app) readParams(params []configParam) error {
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	in := bufio.NewReader(os.Stdin)
+	for _, p := range params[len(a.pageArgs):] {
+		prompt := p.Prompt
+		if prompt == "" {
+			prompt = p.Name
+		}
+		if p.Type == "enum" {
+			prompt += " (" + strings.Join(p.Values, ", ") + ")"
+		}
+		if !interactive && p.Default == "" {
+			return fmt.Errorf("missing parameter %q", p.Name)
+		}
+		for {
+			value := p.Default
+			if interactive {
+				fmt.Fprintf(os.Stderr, "%s [%s]: ", prompt, p.Default)
+				line, err := in.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				if line = strings.TrimSpace(line); line != "" {
+					value = line
+				}
+			}
+			_, err := p.Value(value)
+			if err == nil {
+				a.pageArgs = append(a.pageArgs, value)
+				break
+			}
+			if !interactive {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		}
+	}
+	return nil
+}
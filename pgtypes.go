@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// sqlKind tells how the values of a column are decoded.
+type sqlKind int
+
+const (
+	kindScalar    sqlKind = iota
+	kindArray             // PostgreSQL arrays, decoded as pgArray
+	kindUnknown           // PostgreSQL types without a fixed OID, looked up with pgResultKinds
+	kindHstore            // PostgreSQL hstore, decoded as pgHstore
+	kindComposite         // PostgreSQL composite types, decoded as pgComposite
+)
+
+// pgComposite is a value of a PostgreSQL composite type.
+// NULL fields are nil.
+type pgComposite []*string
+
+// pgHstore is a value of the PostgreSQL hstore type.
+// NULL values are nil.
+type pgHstore map[string]*string
+
+// pgArray is a PostgreSQL array.
+// Its elements are strings, nil for NULL elements,
+// or pgArrays in multidimensional arrays.
+type pgArray []interface{}
+
+// String returns the array as a PostgreSQL array literal.
+func (arr pgArray) String() string {
+	return pgArrayLiteral(arr)
+}
+
+// Value converts the array into a literal to be sent to the database.
+func (arr pgArray) Value() (driver.Value, error) {
+	return pgArrayLiteral(arr), nil
+}
+
+var pgQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// pgArrayLiteral returns an array as a PostgreSQL array literal,
+// with every element quoted.
+func pgArrayLiteral(arr pgArray) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, elem := range arr {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch e := elem.(type) {
+		case nil:
+			b.WriteString("NULL")
+		case pgArray:
+			b.WriteString(pgArrayLiteral(e))
+		default:
+			b.WriteString(`"` + pgQuoter.Replace(sqlString(e)) + `"`)
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// pgParseArray parses a PostgreSQL array literal such as {1,2,"a b",NULL}.
+func pgParseArray(s string) (pgArray, error) {
+	literal := s
+	if strings.HasPrefix(s, "[") {
+		// explicit dimensions, as in [0:1]={1,2}
+		if i := strings.Index(s, "="); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	invalid := fmt.Errorf("invalid array %q", literal)
+	i := 0
+	skipSpaces := func() {
+		for i < len(s) && strings.IndexByte(" \t\n\r", s[i]) >= 0 {
+			i++
+		}
+	}
+	var parse func() (pgArray, error)
+	parse = func() (pgArray, error) {
+		if i >= len(s) || s[i] != '{' {
+			return nil, invalid
+		}
+		i++
+		arr := pgArray{}
+		skipSpaces()
+		if i < len(s) && s[i] == '}' {
+			i++
+			return arr, nil
+		}
+		for {
+			skipSpaces()
+			if i >= len(s) {
+				return nil, invalid
+			}
+			switch s[i] {
+			case '{':
+				sub, err := parse()
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, sub)
+			case '"':
+				var b strings.Builder
+				for i++; i < len(s) && s[i] != '"'; i++ {
+					if s[i] == '\\' && i+1 < len(s) {
+						i++
+					}
+					b.WriteByte(s[i])
+				}
+				if i >= len(s) {
+					return nil, invalid
+				}
+				i++
+				arr = append(arr, b.String())
+			default:
+				var b strings.Builder
+				escaped := false
+				for ; i < len(s) && s[i] != ',' && s[i] != '}'; i++ {
+					if s[i] == '\\' && i+1 < len(s) {
+						i++
+						escaped = true
+					}
+					b.WriteByte(s[i])
+				}
+				elem := strings.TrimSpace(b.String())
+				if !escaped && strings.EqualFold(elem, "NULL") {
+					arr = append(arr, nil)
+				} else {
+					arr = append(arr, elem)
+				}
+			}
+			skipSpaces()
+			if i >= len(s) {
+				return nil, invalid
+			}
+			i++
+			if s[i-1] == '}' {
+				return arr, nil
+			}
+			if s[i-1] != ',' {
+				return nil, invalid
+			}
+		}
+	}
+	arr, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if skipSpaces(); i < len(s) {
+		return nil, invalid
+	}
+	return arr, nil
+}
+
+// pgArrayElements converts the elements of an array, including the ones
+// in nested arrays, into the strings which would be shown for single values
+// of the element type.
+func pgArrayElements(elemType string, arr pgArray) {
+	for i, elem := range arr {
+		switch e := elem.(type) {
+		case pgArray:
+			pgArrayElements(elemType, e)
+		case string:
+			arr[i] = pgArrayElement(elemType, e)
+		}
+	}
+}
+
+// pgArrayElement converts an element of an array into the string
+// which would be shown for a single value of the element type.
+func pgArrayElement(elemType string, s string) string {
+	switch {
+	case oneOf(elemType, boolTypes):
+		switch s {
+		case "t":
+			return "true"
+		case "f":
+			return "false"
+		}
+	case oneOf(elemType, timeTypes):
+		if t, err := pq.ParseTimestamp(nil, s); err == nil {
+			return fieldString(t)
+		}
+	}
+	return s
+}
+
+// String returns the composite value as a PostgreSQL row literal.
+func (c pgComposite) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, field := range c {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if field != nil {
+			b.WriteString(`"` + strings.NewReplacer(`\`, `\\`, `"`, `""`).Replace(*field) + `"`)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Value converts the composite value into a row literal to be sent to the database.
+func (c pgComposite) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+// pgParseComposite splits a PostgreSQL row literal such as (1,"a b",)
+// into its fields, with NULL (empty) fields returned as nil.
+func pgParseComposite(s string) (pgComposite, bool) {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return nil, false
+	}
+	s = s[1 : len(s)-1]
+	var fields pgComposite
+	var cur strings.Builder
+	quoted, inQuote := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case inQuote && c == '"' && i+1 < len(s) && s[i+1] == '"':
+			i++
+			cur.WriteByte('"')
+		case c == '"':
+			inQuote = !inQuote
+			quoted = true
+		case !inQuote && c == ',':
+			fields = append(fields, pgField(cur.String(), quoted))
+			cur.Reset()
+			quoted = false
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if inQuote {
+		return nil, false
+	}
+	return append(fields, pgField(cur.String(), quoted)), true
+}
+
+// pgField returns a field of a composite value, or nil if it is NULL.
+func pgField(s string, quoted bool) *string {
+	if s == "" && !quoted {
+		return nil
+	}
+	return &s
+}
+
+// String returns the hstore value as a PostgreSQL hstore literal,
+// with its keys sorted.
+func (h pgHstore) String() string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		v := "NULL"
+		if h[k] != nil {
+			v = `"` + pgQuoter.Replace(*h[k]) + `"`
+		}
+		pairs[i] = `"` + pgQuoter.Replace(k) + `"=>` + v
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Value converts the hstore value into a literal to be sent to the database.
+func (h pgHstore) Value() (driver.Value, error) {
+	return h.String(), nil
+}
+
+// pgParseHstore parses an hstore literal as returned by PostgreSQL,
+// such as "a"=>"1", "b"=>NULL.
+func pgParseHstore(s string) (pgHstore, bool) {
+	h := make(pgHstore)
+	quotedString := func() (string, bool) {
+		if !strings.HasPrefix(s, `"`) {
+			return "", false
+		}
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) {
+					i++
+					b.WriteByte(s[i])
+				}
+			case '"':
+				s = s[i+1:]
+				return b.String(), true
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return "", false
+	}
+	s = strings.TrimSpace(s)
+	for s != "" {
+		key, ok := quotedString()
+		if !ok || !strings.HasPrefix(s, "=>") {
+			return nil, false
+		}
+		s = s[2:]
+		if strings.HasPrefix(s, "NULL") {
+			h[key] = nil
+			s = s[4:]
+		} else if value, ok := quotedString(); ok {
+			h[key] = &value
+		} else {
+			return nil, false
+		}
+		s = strings.TrimSpace(s)
+		if s != "" {
+			if !strings.HasPrefix(s, ",") {
+				return nil, false
+			}
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+	return h, true
+}
+
+// pgDecode converts a value of an hstore or composite column
+// into a pgHstore or a pgComposite.
+// Values which cannot be parsed are returned as strings.
+func pgDecode(kind sqlKind, b []byte) interface{} {
+	s := string(b)
+	switch kind {
+	case kindHstore:
+		if h, ok := pgParseHstore(s); ok {
+			return h
+		}
+	case kindComposite:
+		if c, ok := pgParseComposite(s); ok {
+			return c
+		}
+	}
+	return s
+}
+
+// pgResultKinds finds out the kinds of the columns of a query marked as kindUnknown,
+// which github.com/lib/pq returns without a type name.
+// It prepares the query to read the types of its result from pg_prepared_statements
+// (available since PostgreSQL 14) and looks them up in pg_type.
+// Columns whose types cannot be found are taken as scalars.
+func pgResultKinds(ctx context.Context, db *sqlx.DB, query string, kinds []sqlKind) {
+	found := make([]sqlKind, len(kinds))
+	defer func() {
+		for i := range kinds {
+			if kinds[i] == kindUnknown {
+				kinds[i] = found[i]
+			}
+		}
+	}()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "PREPARE sqlview_types AS "+sqlTrim(query, false)); err != nil {
+		return
+	}
+	defer conn.ExecContext(ctx, "DEALLOCATE sqlview_types")
+	rows, err := conn.QueryContext(ctx, `SELECT t.typname, t.typtype
+		FROM pg_prepared_statements p,
+			unnest(p.result_types) WITH ORDINALITY AS r(type, n)
+			JOIN pg_type t ON t.oid = r.type::oid
+		WHERE p.name = 'sqlview_types'
+		ORDER BY r.n`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for i := 0; rows.Next() && i < len(found); i++ {
+		var name, typtype string
+		if err := rows.Scan(&name, &typtype); err != nil {
+			return
+		}
+		switch {
+		case typtype == "c":
+			found[i] = kindComposite
+		case name == "hstore":
+			found[i] = kindHstore
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPgArray(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    pgArray
+	}{
+		{`{}`, pgArray{}},
+		{`{1,2,3}`, pgArray{"1", "2", "3"}},
+		{`{NULL,"NULL",null}`, pgArray{nil, "NULL", nil}},
+		{`{"",~,"a b"," c "}`, pgArray{"", "~", "a b", " c "}},
+		{`{"a,b","{x}","q\"uote","back\\slash"}`, pgArray{"a,b", "{x}", `q"uote`, `back\slash`}},
+		{`{{1,2},{NULL,4}}`, pgArray{pgArray{"1", "2"}, pgArray{nil, "4"}}},
+		{`[0:1]={a,b}`, pgArray{"a", "b"}},
+	}
+	for _, test := range tests {
+		got, err := pgParseArray(test.literal)
+		if err != nil {
+			t.Errorf("pgParseArray(%q): %v", test.literal, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("pgParseArray(%q) = %#v, want %#v", test.literal, got, test.want)
+			continue
+		}
+		literal := pgArrayLiteral(got)
+		again, err := pgParseArray(literal)
+		if err != nil {
+			t.Errorf("pgParseArray(%q): %v", literal, err)
+			continue
+		}
+		if !reflect.DeepEqual(again, test.want) {
+			t.Errorf("round trip of %q through %q = %#v, want %#v", test.literal, literal, again, test.want)
+		}
+	}
+}
+
+func TestPgArrayInvalid(t *testing.T) {
+	for _, literal := range []string{``, `1,2`, `{1,2`, `{"a}`, `{1}x`, `{{1}`} {
+		if arr, err := pgParseArray(literal); err == nil {
+			t.Errorf("pgParseArray(%q) = %#v, want an error", literal, arr)
+		}
+	}
+}
+
+func TestPgArrayLiteral(t *testing.T) {
+	arr := pgArray{"a", nil, "NULL", "", `"\`, pgArray{"1"}}
+	want := `{"a",NULL,"NULL","","\"\\",{"1"}}`
+	if got := pgArrayLiteral(arr); got != want {
+		t.Errorf("pgArrayLiteral(%#v) = %q, want %q", arr, got, want)
+	}
+}
+
+func TestPgComposite(t *testing.T) {
+	for _, literal := range []string{`()`, `(1,"a b",)`, `("~",,"")`, `("q""uote","back\\slash","(x,y)")`} {
+		c, ok := pgParseComposite(literal)
+		if !ok {
+			t.Errorf("pgParseComposite(%q) failed", literal)
+			continue
+		}
+		again, ok := pgParseComposite(c.String())
+		if !ok || !reflect.DeepEqual(again, c) {
+			t.Errorf("round trip of %q through %q = %v, want %v", literal, c.String(), again, c)
+		}
+	}
+	c, _ := pgParseComposite(`("~",)`)
+	if len(c) != 2 || c[0] == nil || *c[0] != "~" || c[1] != nil {
+		t.Errorf(`pgParseComposite("(\"~\",)") = %v, want ["~", NULL]`, c)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// queryIO is the input and output of the query prompt.
+// It can be redirected to load the history of queries
+// without showing anything on the screen.
+type queryIO struct {
+	io.Reader
+	io.Writer
+}
+
+func historyFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".sqlview_history")
+}
+
+// queryPrompt returns the line editor used to enter ad-hoc queries,
+// creating it and loading the history file the first time.
+func (a *app) queryPrompt() *term.Terminal {
+	if a.prompt != nil {
+		return a.prompt
+	}
+	var history []string
+	if f, err := os.Open(historyFile()); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if line := strings.TrimSpace(s.Text()); line != "" {
+				history = append(history, line)
+			}
+		}
+		f.Close()
+	}
+	// term.Terminal keeps the last 100 lines
+	if len(history) > 100 {
+		history = history[len(history)-100:]
+	}
+
+	// term.Terminal has no way to add entries to its history,
+	// other than reading them as if they were typed:
+	rw := &queryIO{
+		Reader: strings.NewReader(strings.Join(history, "\r") + "\r"),
+		Writer: io.Discard,
+	}
+	a.prompt = term.NewTerminal(rw, "SQL> ")
+	for range history {
+		a.prompt.ReadLine()
+	}
+	rw.Reader = os.Stdin
+	rw.Writer = os.Stdout
+	return a.prompt
+}
+
+func saveQuery(query string) error {
+	f, err := os.OpenFile(historyFile(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, query)
+	return f.Close()
+}
+
+// readQuery asks the user for a query, and runs it until it succeeds
+// or the user enters an empty line.
+// Errors are shown to the user, who can then enter the query again.
+func (a *app) readQuery() (string, SQLResult, bool) {
+	var result SQLResult
+
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return "", result, false
+	}
+	defer term.Restore(fd, oldState)
+
+	t := a.queryPrompt()
+	if width, height, err := term.GetSize(fd); err == nil {
+		t.SetSize(width, height)
+	}
+	for {
+		query, err := t.ReadLine()
+		query = strings.TrimSpace(query)
+		if err != nil || query == "" {
+			return "", result, false
+		}
+		if err = saveQuery(query); err != nil {
+			fmt.Fprintf(t, "Warning: %s\n", err.Error())
+		}
+		result, err = sqlQuery(context.Background(), a.db, pageSize, sqlOptions{}, query)
+		if err == nil {
+			return query, result, true
+		}
+		fmt.Fprintf(t, "Error: %s\n", err.Error())
+	}
+}
+
+// cmdQuery asks the user for a SQL query and shows its result
+// as a temporary page.
+func (a *app) cmdQuery(row int) {
+	var query string
+	var result SQLResult
+	var ok bool
+
+	a.table.Suspend(func() {
+		query, result, ok = a.readQuery()
+	})
+	if !ok {
+		return
+	}
+	a.result.Close()
+	a.back = append(a.back, a.saveState(row))
+	a.forward = nil
+	a.pageName = ""
+	a.pageArgs = nil
+	a.query = query
+	a.filter = ""
+	a.sortColumn = ""
+	a.result = result
+	a.marked = nil
+	a.fillTable()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Modes of the search prompt
+const (
+	searchNone = iota
+	searchFind
+	searchFilter
+)
+
+// compileSearch compiles a search string as a case-insensitive regular expression,
+// or as literal text if it is not a valid one.
+func compileSearch(text string) *regexp.Regexp {
+	re, err := regexp.Compile("(?i)" + text)
+	if err != nil {
+		re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(text))
+	}
+	return re
+}
+
+// filterFunc returns a function reporting whether a row matches a filter.
+// A filter is a list of terms separated by spaces, all of which must match.
+// Every term is a regular expression to be found in any of the columns,
+// or in a given column if written as "column:regexp".
+func filterFunc(columns []string, filter string) func([]string) bool {
+	type term struct {
+		column int // -1 for any column
+		re     *regexp.Regexp
+	}
+	var terms []term
+	for _, f := range strings.Fields(filter) {
+		t := term{column: -1}
+		if i := strings.Index(f, ":"); i > 0 {
+			for j, c := range columns {
+				if strings.EqualFold(c, f[:i]) {
+					t.column = j
+					f = f[i+1:]
+					break
+				}
+			}
+		}
+		t.re = compileSearch(f)
+		terms = append(terms, t)
+	}
+	return func(row []string) bool {
+		for _, t := range terms {
+			found := false
+			for i, cell := range row {
+				if (t.column == -1 || t.column == i) && t.re.MatchString(cell) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// clientFilter reports whether the rows are filtered by sqlview
+// instead of by the database.
+func (a *app) clientFilter() bool {
+	return a.filter != "" && !a.serverSide()
+}
+
+// rowIndex converts a row in the table into a row in the result of the page,
+// which are different when there is a filter.
+// It returns len(a.result.Strings) if row is not valid.
+func (a *app) rowIndex(row int) int {
+	if !a.clientFilter() {
+		return row
+	}
+	if row < 0 || row >= len(a.rows) {
+		return len(a.result.Strings)
+	}
+	return a.rows[row]
+}
+
+// visibleRows returns the rows shown in the table, after applying the filter.
+func (a *app) visibleRows() [][]string {
+	if !a.clientFilter() {
+		return a.result.Strings
+	}
+	var strs [][]string
+	for _, i := range a.rows {
+		strs = append(strs, a.result.Strings[i])
+	}
+	return strs
+}
+
+// title returns the title of the table: the breadcrumb of pages,
+// the filter in use and the search being typed, if any.
+func (a *app) title() string {
+	title := a.breadcrumb() + a.loadedString()
+	if a.filter != "" && a.searchMode != searchFilter {
+		title += " [filter: " + a.filter + "]"
+	}
+	if a.sortColumn != "" {
+		title += " [sort: " + a.sortColumn
+		if a.sortDesc {
+			title += " desc"
+		}
+		title += "]"
+	}
+	switch a.searchMode {
+	case searchFind:
+		title += " /" + a.searchText
+	case searchFilter:
+		title += " &" + a.searchText
+	}
+	return title
+}
+
+// findNext selects the next row matching the last search,
+// starting at row and going forwards or backwards.
+func (a *app) findNext(row int, forward bool) bool {
+	if a.search == nil {
+		return false
+	}
+	strs := a.visibleRows()
+	for n := 0; n < len(strs); n++ {
+		i := row + n
+		if !forward {
+			i = row - n
+		}
+		i = (i%len(strs) + len(strs)) % len(strs)
+		for _, cell := range strs[i] {
+			if a.search.MatchString(cell) {
+				a.table.Select(i, 0)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startSearch starts reading a search ('/') or a filter ('&') from the keyboard.
+// All the records of the page are read first (unless the filter is done
+// by the database), so the search does not miss the ones not read yet.
+func (a *app) startSearch(mode int, row int) {
+	if !(a.serverSide() && mode == searchFilter) {
+		a.loadMore(tcell.KeyEnd, row)
+	}
+	a.searchMode = mode
+	a.searchRow = row
+	a.searchText = ""
+	if mode == searchFilter {
+		a.searchText = a.filter
+		a.searchUndo = a.filter
+	}
+	a.table.SetTitle(a.title())
+}
+
+// searchKey handles the keys pressed while reading a search or a filter.
+// Searches and filters are applied as they are typed;
+// Enter keeps them and Esc cancels them.
+func (a *app) searchKey(key tcell.Key, r rune) {
+	switch key {
+	case tcell.KeyCR:
+		a.searchMode = searchNone
+		a.table.SetTitle(a.title())
+		return
+	case tcell.KeyESC:
+		if a.searchMode == searchFilter {
+			a.searchText = a.searchUndo
+			a.setFilter(a.searchText)
+		} else {
+			a.table.Select(a.searchRow, 0)
+		}
+		a.searchMode = searchNone
+		a.table.SetTitle(a.title())
+		return
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if runes := []rune(a.searchText); len(runes) > 0 {
+			a.searchText = string(runes[:len(runes)-1])
+		}
+	case tcell.KeyRune:
+		a.searchText += string(r)
+	default:
+		return
+	}
+	if a.searchMode == searchFilter {
+		a.setFilter(a.searchText)
+		return
+	}
+	a.search = nil
+	if a.searchText != "" {
+		a.search = compileSearch(a.searchText)
+		a.findNext(a.searchRow, true)
+	}
+	a.table.SetTitle(a.title())
+}
+
+// setFilter changes the filter of the rows shown in the table.
+func (a *app) setFilter(filter string) {
+	a.filter = strings.TrimSpace(filter)
+	a.fillTable()
+}
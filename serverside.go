@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// serverSide reports whether sorting and filtering of the current page
+// are done by the database.
+func (a *app) serverSide() bool {
+	return a.query == "" && a.Pages[a.pageName].ServerSide
+}
+
+// sqlQuoteIdent quotes the name of a column.
+func (a *app) sqlQuoteIdent(name string) string {
+	if a.db != nil && a.db.DriverName() == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// serverFilterOps are the operators allowed in server-side filters,
+// longest first so "<=" is not taken as "<".
+var serverFilterOps = []struct {
+	op  string
+	sql string
+}{
+	{"<=", "<="},
+	{">=", ">="},
+	{"!=", "<>"},
+	{"=", "="},
+	{"<", "<"},
+	{">", ">"},
+	{":", "LIKE"},
+}
+
+// serverQuery wraps the select statement of the current page as a subquery,
+// adding the filter and sort order, with the values of the filter
+// bound as new positional parameters after the ones in args.
+// Every term of the filter must be "column<op>value", where <op> is one of
+// "=", "!=", "<", "<=", ">", ">=" or ":" (which looks for value inside the column).
+func (a *app) serverQuery(args []interface{}) (string, []interface{}, error) {
+	page := a.Pages[a.pageName]
+	if a.filter == "" && a.sortColumn == "" {
+		return page.Select, args, nil
+	}
+	var where []string
+	for _, term := range strings.Fields(a.filter) {
+		var column, op, value string
+		for _, o := range serverFilterOps {
+			if i := strings.Index(term, o.op); i > 0 {
+				column, op, value = term[:i], o.sql, term[i+len(o.op):]
+				break
+			}
+		}
+		if column == "" {
+			return "", nil, fmt.Errorf("filter %q: must be column=value, column:value...", term)
+		}
+		if !a.hasColumn(column) {
+			return "", nil, fmt.Errorf("filter %q: unknown column %q", term, column)
+		}
+		expr := a.sqlQuoteIdent(column)
+		if op == "LIKE" {
+			// LIKE only works on text columns
+			value = "%" + value + "%"
+			expr = a.sqlText(expr)
+		}
+		args = append(args, value)
+		where = append(where, fmt.Sprintf("%s %s $%d", expr, op, len(args)))
+	}
+	query := "SELECT * FROM (" + sqlTrim(page.Select, sqlBackslashes(a.db)) + ") AS sqlview"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if a.sortColumn != "" {
+		query += " ORDER BY " + a.sqlQuoteIdent(a.sortColumn)
+		if a.sortDesc {
+			query += " DESC"
+		}
+	}
+	return query, args, nil
+}
+
+// sqlText converts an expression into text.
+func (a *app) sqlText(expr string) string {
+	if a.db != nil && a.db.DriverName() == "mysql" {
+		return "CAST(" + expr + " AS CHAR)"
+	}
+	return "CAST(" + expr + " AS TEXT)"
+}
+
+func (a *app) hasColumn(name string) bool {
+	for _, c := range a.result.Columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setServerFilter changes the filter of a server-side page and runs its query again.
+// If the query fails, the previous filter is kept.
+func (a *app) setServerFilter(filter string) {
+	old := a.filter
+	a.filter = strings.TrimSpace(filter)
+	if err := a.reloadPage(); err != nil {
+		a.filter = old
+		a.showError(err)
+	}
+	a.fillTable()
+}
+
+// cmdSort sorts a server-side page by the selected column,
+// or reverses the order if it was already sorted by it.
+func (a *app) cmdSort() {
+	if !a.serverSide() {
+		return
+	}
+	_, col := a.table.GetSelection()
+	col = a.columnIndex(col)
+	if col < 0 {
+		return
+	}
+	oldColumn, oldDesc := a.sortColumn, a.sortDesc
+	if a.sortColumn == a.result.Columns[col] {
+		a.sortDesc = !a.sortDesc
+	} else {
+		a.sortColumn = a.result.Columns[col]
+		a.sortDesc = false
+	}
+	if err := a.reloadPage(); err != nil {
+		a.sortColumn, a.sortDesc = oldColumn, oldDesc
+		a.showError(err)
+		return
+	}
+	a.fillTable()
+}
+
+// serverSearchKey handles the keys pressed while reading the filter of a server-side page.
+// The filter is only applied when Enter is pressed.
+func (a *app) serverSearchKey(key tcell.Key, r rune) {
+	switch key {
+	case tcell.KeyCR:
+		a.searchMode = searchNone
+		a.setServerFilter(a.searchText)
+		return
+	case tcell.KeyESC:
+		a.searchMode = searchNone
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if runes := []rune(a.searchText); len(runes) > 0 {
+			a.searchText = string(runes[:len(runes)-1])
+		}
+	case tcell.KeyRune:
+		a.searchText += string(r)
+	}
+	a.table.SetTitle(a.title())
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldString(t *testing.T) {
+	madrid := time.FixedZone("", 2*60*60)
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC), "2022-03-04"},
+		{time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC), "2022-03-04 05:06:07"},
+		{time.Date(2022, 3, 4, 5, 6, 7, 123456789, time.UTC), "2022-03-04 05:06:07.123456789"},
+		{time.Date(2022, 3, 4, 0, 0, 0, 0, madrid), "2022-03-04 00:00:00+02:00"},
+		{time.Date(2022, 3, 4, 5, 6, 7, 500000000, madrid), "2022-03-04 05:06:07.5+02:00"},
+		{time.Date(0, 1, 1, 5, 6, 7, 250000000, time.UTC), "05:06:07.25"},
+		{time.Date(0, 1, 1, 5, 6, 7, 0, madrid), "05:06:07+02:00"},
+		{int64(42), "42"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := fieldString(test.value); got != test.want {
+			t.Errorf("fieldString(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+	for _, test := range tests[:7] {
+		s := fieldString(test.value)
+		if err := (SQLColumn{Name: "t", DatabaseType: "TIMESTAMPTZ"}).Validate(s); err != nil {
+			t.Errorf("Validate(%q): %v", s, err)
+		}
+	}
+}